@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"matchlog/internal/achievement"
+	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/importer"
+	"matchlog/internal/match"
+	"matchlog/internal/rating"
+	"matchlog/internal/statistic"
+	"matchlog/internal/team"
+	"matchlog/internal/user"
+	"matchlog/pkg/database"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// importMatchesCmd represents the import-matches command.
+var importMatchesCmd = &cobra.Command{
+	Use:   "import-matches",
+	Short: "Import historical matches from a CSV or JSON file",
+	Long: "Import historical matches of a game into a club from a CSV or JSON file. " +
+		"Matches are recorded as confirmed at the time they were played, and statistics and ratings are replayed in chronological order. " +
+		"Players without an account are added to the club as virtual users.",
+	Run: importMatches,
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(importMatchesCmd)
+
+	importMatchesCmd.Flags().String("file", "", "file to import")
+	importMatchesCmd.Flags().String("format", "", "format of the file, csv or json (default from the file extension)")
+	importMatchesCmd.Flags().Uint("club", 0, "id of the club the matches were played in")
+	importMatchesCmd.Flags().Uint("game", 0, "id of the game the matches were played in")
+	importMatchesCmd.Flags().Uint("by", 0, "id of the user the matches are recorded as submitted by")
+	importMatchesCmd.Flags().Bool("dry-run", false, "validate the file without importing anything")
+
+	_ = importMatchesCmd.MarkFlagRequired("file")
+	_ = importMatchesCmd.MarkFlagRequired("club")
+	_ = importMatchesCmd.MarkFlagRequired("game")
+}
+
+func importMatches(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	config := loadConfig()
+
+	l := GetLogger(config.LogEnv)
+
+	path, _ := cmd.Flags().GetString("file")
+	format, _ := cmd.Flags().GetString("format")
+	clubId, _ := cmd.Flags().GetUint("club")
+	gameId, _ := cmd.Flags().GetUint("game")
+	importedBy, _ := cmd.Flags().GetUint("by")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		l.Fatal("Failed to open import file",
+			"error", err)
+	}
+	defer file.Close()
+
+	records, err := importer.Parse(file, importer.Format(strings.ToLower(format)))
+	if err != nil {
+		l.Fatal("Failed to parse import file",
+			"error", err)
+	}
+
+	db, err := database.NewClient(ctx, config.DBDSN)
+	if err != nil {
+		l.Fatal("Failed to connect to database",
+			"error", err)
+	}
+
+	transactor := database.NewTransactor(db)
+
+	userService := user.NewService(user.NewRepository(db))
+	clubService := club.NewService(club.NewRepository(db), transactor, config.InviteExpiry)
+	gameService := game.NewService(game.NewRepository(db))
+	teamService := team.NewService(team.NewRepository(db))
+	statisticService := statistic.NewService(statistic.NewRepository(db))
+	ratingService := rating.NewService(rating.NewRepository(db))
+	achievementService := achievement.NewService(achievement.NewRepository(db), statisticService)
+	matchService := match.NewService(match.NewRepository(db), transactor, clubService, gameService, teamService, statisticService, ratingService, achievementService)
+	importerService := importer.NewService(transactor, userService, clubService, matchService)
+
+	result, err := importerService.ImportMatches(ctx, clubId, gameId, importedBy, records, dryRun)
+	if err != nil {
+		l.Fatal("Failed to import matches",
+			"error", err)
+	}
+
+	if dryRun {
+		fmt.Printf("dry run: %d matches would be imported\n", result.Imported)
+	} else {
+		fmt.Printf("imported %d matches\n", result.Imported)
+	}
+
+	if len(result.VirtualUsers) > 0 {
+		fmt.Printf("virtual users: %s\n", strings.Join(result.VirtualUsers, ", "))
+	}
+}
@@ -35,6 +35,26 @@ func migrate(cmd *cobra.Command, args []string) {
 
 	m := gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
 		migrations.Migration00001Init,
+		migrations.Migration00002Teams,
+		migrations.Migration00003MatchHistory,
+		migrations.Migration00004MatchSubmitter,
+		migrations.Migration00005MatchConfirmation,
+		migrations.Migration00006ClubStandings,
+		migrations.Migration00007GameRules,
+		migrations.Migration00008MatchEvents,
+		migrations.Migration00009MatchTypes,
+		migrations.Migration00010MatchIdempotencyKey,
+		migrations.Migration00011RicherStatistics,
+		migrations.Migration00012Achievements,
+		migrations.Migration00013LeaderboardSnapshots,
+		migrations.Migration00014GlobalLeaderboards,
+		migrations.Migration00015ClubInvites,
+		migrations.Migration00016ClubJoining,
+		migrations.Migration00017MatchReopening,
+		migrations.Migration00018ClubScopeBackfill,
+		migrations.Migration00019MatchSubmissionHash,
+		migrations.Migration00020RatingStartValue,
+		migrations.Migration00021UniqueStandings,
 	})
 
 	if err = m.Migrate(); err != nil {
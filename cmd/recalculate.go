@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"matchlog/internal/achievement"
+	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/match"
+	"matchlog/internal/rating"
+	"matchlog/internal/statistic"
+	"matchlog/internal/team"
+	"matchlog/pkg/database"
+
+	"github.com/spf13/cobra"
+)
+
+// recalculateCmd represents the recalculate command.
+var recalculateCmd = &cobra.Command{
+	Use:   "recalculate",
+	Short: "Replay statistics and ratings from the match history",
+	Long: "Replay the statistics and ratings of a club from its confirmed matches, in the order they were confirmed. " +
+		"Use it after a migration adds to what statistics keep track of.",
+	Run: recalculate,
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(recalculateCmd)
+
+	recalculateCmd.Flags().Uint("club", 0, "id of the club to recalculate")
+	recalculateCmd.Flags().Uint("game", 0, "id of the game to recalculate (default every game played in the club)")
+
+	_ = recalculateCmd.MarkFlagRequired("club")
+}
+
+func recalculate(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	config := loadConfig()
+
+	l := GetLogger(config.LogEnv)
+
+	clubId, _ := cmd.Flags().GetUint("club")
+	gameId, _ := cmd.Flags().GetUint("game")
+
+	db, err := database.NewClient(ctx, config.DBDSN)
+	if err != nil {
+		l.Fatal("Failed to connect to database",
+			"error", err)
+	}
+
+	transactor := database.NewTransactor(db)
+
+	clubService := club.NewService(club.NewRepository(db), transactor, config.InviteExpiry)
+	gameService := game.NewService(game.NewRepository(db))
+	teamService := team.NewService(team.NewRepository(db))
+	statisticService := statistic.NewService(statistic.NewRepository(db))
+	ratingService := rating.NewService(rating.NewRepository(db))
+	achievementService := achievement.NewService(achievement.NewRepository(db), statisticService)
+	matchService := match.NewService(match.NewRepository(db), transactor, clubService, gameService, teamService, statisticService, ratingService, achievementService)
+
+	gameIds := []uint{gameId}
+	if gameId == 0 {
+		gameIds, err = clubService.GetGameIdsInClub(ctx, clubId)
+		if err != nil {
+			l.Fatal("Failed to get games in club",
+				"error", err)
+		}
+	}
+
+	for _, id := range gameIds {
+		if err := matchService.Recalculate(ctx, clubId, id); err != nil {
+			l.Fatal("Failed to recalculate",
+				"game", id,
+				"error", err)
+		}
+
+		fmt.Printf("recalculated game %d\n", id)
+	}
+}
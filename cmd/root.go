@@ -10,7 +10,11 @@ import (
 	"go.uber.org/zap"
 )
 
-const shutdownPeriod = 15 * time.Second
+const (
+	shutdownPeriod      = 15 * time.Second
+	autoConfirmInterval = time.Minute
+	idleLiveInterval    = time.Minute
+)
 
 type Config struct {
 	LogEnv        string        `env:"LOG_ENV"`
@@ -18,6 +22,14 @@ type Config struct {
 	Port          int           `env:"PORT" envDefault:"8000"`
 	JWTSecret     string        `env:"JWT_SECRET"`
 	JWTExpiration time.Duration `env:"JWT_EXPIRATION"`
+
+	MatchConfirmationTimeout    time.Duration `env:"MATCH_CONFIRMATION_TIMEOUT" envDefault:"48h"`
+	LeaderboardSnapshotInterval time.Duration `env:"LEADERBOARD_SNAPSHOT_INTERVAL" envDefault:"24h"`
+	InviteExpiry                time.Duration `env:"INVITE_EXPIRY" envDefault:"168h"`
+	LiveMatchIdleTimeout        time.Duration `env:"LIVE_MATCH_IDLE_TIMEOUT" envDefault:"2h"`
+
+	// DebugAddress is where /debug/vars is served, which is left out if it is empty.
+	DebugAddress string `env:"DEBUG_ADDRESS" envDefault:"127.0.0.1:6060"`
 }
 
 var rootCmd = &cobra.Command{
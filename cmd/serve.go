@@ -3,19 +3,27 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"matchlog/internal/achievement"
 	"matchlog/internal/authentication"
 	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/importer"
 	"matchlog/internal/leaderboard"
+	"matchlog/internal/live"
 	"matchlog/internal/match"
 	"matchlog/internal/rating"
 	"matchlog/internal/rest"
 	"matchlog/internal/statistic"
+	"matchlog/internal/team"
 	"matchlog/internal/user"
 	"matchlog/pkg/database"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -44,21 +52,23 @@ func serve(cmd *cobra.Command, args []string) {
 			"error", err)
 	}
 
+	transactor := database.NewTransactor(db)
+
 	// Initialize User service
 	userRepository := user.NewRepository(db)
 	userService := user.NewService(userRepository)
 
 	// Initialize Club service
 	clubRepository := club.NewRepository(db)
-	clubService := club.NewService(clubRepository)
+	clubService := club.NewService(clubRepository, transactor, config.InviteExpiry)
+
+	// Initialize Game service
+	gameRepository := game.NewRepository(db)
+	gameService := game.NewService(gameRepository)
 
 	// Initialize Authentication service
 	authenticationService := authentication.NewService(config.JWTSecret, userService)
 
-	// Initialize Match service
-	matchRepository := match.NewRepository(db)
-	matchService := match.NewService(matchRepository)
-
 	// Initialize Rating service
 	ratingRepository := rating.NewRepository(db)
 	ratingService := rating.NewService(ratingRepository)
@@ -67,8 +77,27 @@ func serve(cmd *cobra.Command, args []string) {
 	statisticRepository := statistic.NewRepository(db)
 	statisticService := statistic.NewService(statisticRepository)
 
+	// Initialize Team service
+	teamRepository := team.NewRepository(db)
+	teamService := team.NewService(teamRepository)
+
+	// Initialize Achievement service
+	achievementRepository := achievement.NewRepository(db)
+	achievementService := achievement.NewService(achievementRepository, statisticService)
+
+	// Initialize Match service
+	matchRepository := match.NewRepository(db)
+	matchService := match.NewService(matchRepository, transactor, clubService, gameService, teamService, statisticService, ratingService, achievementService)
+
+	// Initialize Live match service
+	liveService := live.NewService(clubService, matchService, config.LiveMatchIdleTimeout)
+
+	// Initialize Importer service
+	importerService := importer.NewService(transactor, userService, clubService, matchService)
+
 	// Initialize Leaderboard service
-	leaderboardService := leaderboard.NewService(clubService, userService, ratingService, statisticService)
+	leaderboardRepository := leaderboard.NewRepository(db)
+	leaderboardService := leaderboard.NewService(leaderboardRepository, clubService, userService, ratingService, statisticService, teamService, matchService)
 
 	// Initialize REST server
 	restServer, err := rest.NewServer(
@@ -81,6 +110,11 @@ func serve(cmd *cobra.Command, args []string) {
 		ratingService,
 		statisticService,
 		leaderboardService,
+		teamService,
+		gameService,
+		liveService,
+		importerService,
+		achievementService,
 	)
 	if err != nil {
 		l.Fatal("Failed to create rest server",
@@ -102,6 +136,81 @@ func serve(cmd *cobra.Command, args []string) {
 		}
 	}()
 
+	// Start the debug server, away from the public API
+	var debugServer *rest.Server
+	if config.DebugAddress != "" {
+		debugServer = rest.NewDebugServer(config.DebugAddress)
+
+		l.Infow("Debug server starting",
+			"address", config.DebugAddress)
+		go func() {
+			if err := debugServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				l.Error("Failed to start debug server",
+					"error", err)
+			}
+		}()
+	}
+
+	// Confirm matches nobody responded to in time
+	go func() {
+		ticker := time.NewTicker(autoConfirmInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				confirmed, err := matchService.ConfirmExpiredMatches(ctx, config.MatchConfirmationTimeout)
+				if err != nil {
+					l.Error("Failed to auto-confirm matches",
+						"error", err)
+				}
+
+				if confirmed > 0 {
+					l.Infow("Auto-confirmed matches",
+						"count", confirmed)
+				}
+			}
+		}
+	}()
+
+	// Cancel live matches nobody finished
+	go func() {
+		ticker := time.NewTicker(idleLiveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if cancelled := liveService.CancelIdleMatches(ctx); cancelled > 0 {
+					l.Infow("Cancelled idle live matches",
+						"count", cancelled)
+				}
+			}
+		}
+	}()
+
+	// Snapshot leaderboards, which rank changes and trends are measured against
+	go func() {
+		ticker := time.NewTicker(config.LeaderboardSnapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := leaderboardService.TakeSnapshots(ctx); err != nil {
+					l.Error("Failed to snapshot leaderboards",
+						"error", err)
+				}
+			}
+		}
+	}()
+
 	l.Info("Ready")
 
 	fmt.Println("ready")
@@ -118,4 +227,11 @@ func serve(cmd *cobra.Command, args []string) {
 		l.Error("Failed to shutdown rest server",
 			"error", err)
 	}
+
+	if debugServer != nil {
+		if err := debugServer.Shutdown(shutdownctx); err != nil {
+			l.Error("Failed to shutdown debug server",
+				"error", err)
+		}
+	}
 }
@@ -0,0 +1,67 @@
+package achievement
+
+import (
+	"matchlog/internal/statistic"
+	"time"
+)
+
+type Badge string
+
+const (
+	BadgeFirstWin    Badge = "first-win"
+	BadgeWinStreak   Badge = "win-streak"
+	BadgeShutout     Badge = "shutout"
+	BadgeGiantSlayer Badge = "giant-slayer"
+	BadgeCentury     Badge = "century"
+)
+
+// Achievement is a badge awarded to a user, for the match it was earned in.
+// A badge is only awarded once per user.
+type Achievement struct {
+	Id uint `gorm:"primaryKey"`
+
+	UserId  uint  `gorm:"not null;uniqueIndex:idx_achievements_user_badge"`
+	Badge   Badge `gorm:"not null;size:64;uniqueIndex:idx_achievements_user_badge"`
+	ClubId  uint  `gorm:"index"`
+	GameId  uint
+	MatchId uint
+
+	AwardedAt time.Time `gorm:"not null"`
+}
+
+// MatchResult is a confirmed match as far as achievements are concerned.
+type MatchResult struct {
+	MatchId uint
+	ClubId  uint
+	GameId  uint
+
+	TeamA   []uint
+	TeamB   []uint
+	ScoresA []int
+	ScoresB []int
+
+	// WinnerIds are the players who won, none on a draw.
+	WinnerIds []uint
+	// TopRatedUserId is who was rated highest in the game in the club before the match, if anyone was.
+	TopRatedUserId uint
+	// Statistics are those of the players right after the match.
+	// Evaluate reads the stored statistics instead, as it runs right after the match has been applied to them.
+	Statistics map[uint]statistic.Statistic
+}
+
+// Facts are what rules are evaluated against, for a single player of a match.
+type Facts struct {
+	// Statistic is the statistic of the player after the match was applied.
+	Statistic statistic.Statistic
+	Won       bool
+	// Sets are the scores of the side of the player and the other side in every set.
+	Sets         [][2]int
+	BeatTopRated bool
+}
+
+// Rule awards a badge to a player whose facts after a match meet it.
+type Rule struct {
+	Badge       Badge
+	Description string
+	Earned      func(facts Facts) bool
+}
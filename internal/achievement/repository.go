@@ -0,0 +1,64 @@
+package achievement
+
+import (
+	"context"
+	"matchlog/pkg/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	GetAchievementsByUserIds(ctx context.Context, userIds []uint) ([]Achievement, error)
+	CreateAchievements(ctx context.Context, achievements []Achievement) error
+	DeleteAchievements(ctx context.Context, ids []uint) error
+}
+
+type RepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &RepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *RepositoryImpl) GetAchievementsByUserIds(ctx context.Context, userIds []uint) ([]Achievement, error) {
+	var achievements []Achievement
+	result := database.Conn(ctx, r.db).
+		Where("user_id IN ?", userIds).
+		Order("awarded_at asc, id asc").
+		Find(&achievements)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return achievements, nil
+}
+
+// CreateAchievements skips badges the user was awarded in the meantime.
+func (r *RepositoryImpl) CreateAchievements(ctx context.Context, achievements []Achievement) error {
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&achievements)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteAchievements(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	result := database.Conn(ctx, r.db).
+		Delete(&Achievement{}, ids)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package achievement
+
+const (
+	winStreakLength  = 10
+	shutoutScore     = 10
+	centuryOfMatches = 100
+)
+
+// Rules are every badge there is to earn. Adding a badge only takes adding a rule.
+var Rules = []Rule{
+	{
+		Badge:       BadgeFirstWin,
+		Description: "Won a match",
+		Earned: func(facts Facts) bool {
+			return facts.Won
+		},
+	},
+	{
+		Badge:       BadgeWinStreak,
+		Description: "Won 10 matches in a row",
+		Earned: func(facts Facts) bool {
+			return facts.Statistic.Streak >= winStreakLength
+		},
+	},
+	{
+		Badge:       BadgeShutout,
+		Description: "Won a set 10-0",
+		Earned: func(facts Facts) bool {
+			for _, set := range facts.Sets {
+				if set[0] >= shutoutScore && set[1] == 0 {
+					return true
+				}
+			}
+
+			return false
+		},
+	},
+	{
+		Badge:       BadgeGiantSlayer,
+		Description: "Beat the highest rated player",
+		Earned: func(facts Facts) bool {
+			return facts.BeatTopRated
+		},
+	},
+	{
+		Badge:       BadgeCentury,
+		Description: "Played 100 matches",
+		Earned: func(facts Facts) bool {
+			return facts.Statistic.Matches() >= centuryOfMatches
+		},
+	},
+}
+
+// Describe returns the description of a badge.
+func Describe(badge Badge) string {
+	for _, rule := range Rules {
+		if rule.Badge == badge {
+			return rule.Description
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,210 @@
+package achievement
+
+import (
+	"context"
+	"matchlog/internal/statistic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Service interface {
+	GetAchievements(ctx context.Context, userId uint) ([]Achievement, error)
+	Evaluate(ctx context.Context, result MatchResult) (awarded []Achievement, err error)
+	Reevaluate(ctx context.Context, clubId, gameId uint, userIds []uint, changedMatchIds []uint, results []MatchResult) error
+}
+
+type ServiceImpl struct {
+	repo             Repository
+	statisticService statistic.Service
+}
+
+func NewService(repo Repository, statisticService statistic.Service) Service {
+	return &ServiceImpl{
+		repo:             repo,
+		statisticService: statisticService,
+	}
+}
+
+func (s *ServiceImpl) GetAchievements(ctx context.Context, userId uint) ([]Achievement, error) {
+	achievements, err := s.repo.GetAchievementsByUserIds(ctx, []uint{userId})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get achievements of user %d", userId)
+	}
+
+	return achievements, nil
+}
+
+// Evaluate awards the players of a confirmed match every badge they earned with it and did not have yet.
+// It reads statistics, so it has to run after the match has been applied to them.
+func (s *ServiceImpl) Evaluate(ctx context.Context, result MatchResult) ([]Achievement, error) {
+	players := result.players()
+
+	earlier, err := s.repo.GetAchievementsByUserIds(ctx, players)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get earlier achievements")
+	}
+
+	result.Statistics = make(map[uint]statistic.Statistic, len(players))
+	for _, userId := range players {
+		stats, err := s.statisticService.GetStatisticByUserId(ctx, result.ClubId, result.GameId, userId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get statistics of user %d", userId)
+		}
+
+		result.Statistics[userId] = *stats
+	}
+
+	achievements := earn(result, players, awardedBadges(earlier), time.Now())
+	if len(achievements) == 0 {
+		return nil, nil
+	}
+
+	if err := s.repo.CreateAchievements(ctx, achievements); err != nil {
+		return nil, errors.Wrap(err, "failed to create achievements")
+	}
+
+	return achievements, nil
+}
+
+// Reevaluate takes back the badges the users were awarded in the game in the club, and evaluates the results again in order,
+// for when the changed matches were corrected, deleted, reopened or imported. The results are every confirmed match there,
+// and have to carry the statistics of their players right after them.
+// Who was rated highest before a match can not be told from a replay, so badges for beating them are kept,
+// unless they were awarded with a changed match or with a match that is no longer confirmed.
+func (s *ServiceImpl) Reevaluate(ctx context.Context, clubId, gameId uint, userIds []uint, changedMatchIds []uint, results []MatchResult) error {
+	earlier, err := s.repo.GetAchievementsByUserIds(ctx, userIds)
+	if err != nil {
+		return errors.Wrap(err, "failed to get earlier achievements")
+	}
+
+	confirmed := make(map[uint]bool, len(results))
+	for _, result := range results {
+		confirmed[result.MatchId] = true
+	}
+
+	for _, matchId := range changedMatchIds {
+		confirmed[matchId] = false
+	}
+
+	var kept []Achievement
+	var revokedIds []uint
+	for _, a := range earlier {
+		inGame := a.ClubId == clubId && a.GameId == gameId
+		keep := a.Badge == BadgeGiantSlayer && confirmed[a.MatchId]
+		if inGame && !keep {
+			revokedIds = append(revokedIds, a.Id)
+			continue
+		}
+
+		kept = append(kept, a)
+	}
+
+	if err := s.repo.DeleteAchievements(ctx, revokedIds); err != nil {
+		return errors.Wrapf(err, "failed to revoke achievements of game %d in club %d", gameId, clubId)
+	}
+
+	awarded := awardedBadges(kept)
+	now := time.Now()
+
+	var achievements []Achievement
+	for _, result := range results {
+		achievements = append(achievements, earn(result, userIds, awarded, now)...)
+	}
+
+	if len(achievements) == 0 {
+		return nil
+	}
+
+	if err := s.repo.CreateAchievements(ctx, achievements); err != nil {
+		return errors.Wrap(err, "failed to create achievements")
+	}
+
+	return nil
+}
+
+// awardedBadges returns the badges of every user among the achievements.
+func awardedBadges(achievements []Achievement) map[uint]map[Badge]bool {
+	awarded := map[uint]map[Badge]bool{}
+	for _, a := range achievements {
+		if awarded[a.UserId] == nil {
+			awarded[a.UserId] = map[Badge]bool{}
+		}
+
+		awarded[a.UserId][a.Badge] = true
+	}
+
+	return awarded
+}
+
+// earn returns the badges the given users earned with the match and did not have yet, and marks them as awarded.
+// Users that did not play the match are skipped.
+func earn(result MatchResult, userIds []uint, awarded map[uint]map[Badge]bool, now time.Time) []Achievement {
+	var achievements []Achievement
+	for _, userId := range userIds {
+		stats, ok := result.Statistics[userId]
+		if !ok {
+			continue
+		}
+
+		facts := result.facts(userId, stats)
+
+		for _, rule := range Rules {
+			if awarded[userId][rule.Badge] || !rule.Earned(facts) {
+				continue
+			}
+
+			if awarded[userId] == nil {
+				awarded[userId] = map[Badge]bool{}
+			}
+
+			awarded[userId][rule.Badge] = true
+			achievements = append(achievements, Achievement{
+				UserId:    userId,
+				Badge:     rule.Badge,
+				ClubId:    result.ClubId,
+				GameId:    result.GameId,
+				MatchId:   result.MatchId,
+				AwardedAt: now,
+			})
+		}
+	}
+
+	return achievements
+}
+
+func (r MatchResult) players() []uint {
+	return append(append([]uint{}, r.TeamA...), r.TeamB...)
+}
+
+// facts returns the facts of the match from the point of view of one of its players.
+func (r MatchResult) facts(userId uint, stats statistic.Statistic) Facts {
+	facts := Facts{
+		Statistic: stats,
+		Won:       containsId(r.WinnerIds, userId),
+	}
+
+	scoresFor, scoresAgainst := r.ScoresA, r.ScoresB
+	if containsId(r.TeamB, userId) {
+		scoresFor, scoresAgainst = r.ScoresB, r.ScoresA
+	}
+
+	for i := range scoresFor {
+		facts.Sets = append(facts.Sets, [2]int{scoresFor[i], scoresAgainst[i]})
+	}
+
+	topRatedPlayed := containsId(r.TeamA, r.TopRatedUserId) || containsId(r.TeamB, r.TopRatedUserId)
+	facts.BeatTopRated = facts.Won && topRatedPlayed && !containsId(r.WinnerIds, r.TopRatedUserId)
+
+	return facts
+}
+
+func containsId(ids []uint, id uint) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -12,10 +12,23 @@ const (
 	MemberRole  Role = "member"
 )
 
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusAccepted Status = "accepted"
+	StatusDeclined Status = "declined"
+	StatusRevoked  Status = "revoked"
+	// StatusExpired is never stored, a pending invite is expired once its ExpiresAt has passed.
+	StatusExpired Status = "expired"
+)
+
 type Club struct {
 	Id uint `gorm:"primaryKey"`
 
 	Name string `gorm:"not null"`
+	// Discoverable clubs can be found by searching for them, and users can ask to join them.
+	Discoverable bool `gorm:"not null;default:false"`
 
 	CreatedAt time.Time
 }
@@ -26,12 +39,23 @@ type ClubsUsers struct {
 	ClubId uint `gorm:"primaryKey"`
 	UserId uint `gorm:"primaryKey"`
 
-	Accepted bool `gorm:"default:false"`
-	Role     Role `gorm:"default:member"`
+	Status    Status `gorm:"not null;index;default:pending"`
+	Role      Role   `gorm:"default:member"`
+	InvitedBy uint
+	ExpiresAt *time.Time
 
 	CreatedAt time.Time
 }
 
+// CurrentStatus is the status of the membership at the given time, taking the expiry of pending invites into account.
+func (c ClubsUsers) CurrentStatus(now time.Time) Status {
+	if c.Status == StatusPending && c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return StatusExpired
+	}
+
+	return c.Status
+}
+
 type ClubsGames struct {
 	Id uint `gorm:"primaryKey"`
 
@@ -49,3 +73,46 @@ type ClubsTournaments struct {
 
 	CreatedAt time.Time
 }
+
+// InviteLink lets anyone with its code join the club as a member, until it expires, is revoked or is used up.
+type InviteLink struct {
+	Id uint `gorm:"primaryKey"`
+
+	ClubId    uint   `gorm:"not null;index"`
+	Code      string `gorm:"not null;size:32;uniqueIndex"`
+	CreatedBy uint
+	// MaxUses of 0 lets the link be used any number of times before it expires.
+	MaxUses   int
+	Uses      int
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+
+	CreatedAt time.Time
+}
+
+// Usable reports whether the link can still be used to join the club at the given time.
+func (l InviteLink) Usable(now time.Time) bool {
+	if l.RevokedAt != nil || !now.Before(l.ExpiresAt) {
+		return false
+	}
+
+	return l.MaxUses == 0 || l.Uses < l.MaxUses
+}
+
+// JoinRequest is a user asking to join a discoverable club, which a manager approves or declines.
+type JoinRequest struct {
+	Id uint `gorm:"primaryKey"`
+
+	ClubId     uint   `gorm:"not null;index"`
+	UserId     uint   `gorm:"not null;index"`
+	Message    string `gorm:"size:500"`
+	Status     Status `gorm:"not null;index;default:pending"`
+	ReviewedBy uint
+
+	CreatedAt time.Time
+}
+
+// MembersChanged is published once a change to who is in a club is committed.
+type MembersChanged struct {
+	ClubId uint
+}
@@ -0,0 +1,266 @@
+package club
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrAlreadyMember         = errors.New("user is already a member of the club")
+	ErrLinkNotUsable         = errors.New("invite link has expired, been revoked or been used up")
+	ErrInvalidExpiry         = errors.New("expiry must be in the future")
+	ErrJoinRequestPending    = errors.New("user has already asked to join the club")
+	ErrJoinRequestNotPending = errors.New("join request has already been reviewed")
+)
+
+// inviteCodeBytes is the amount of randomness in an invite code, which is base64 encoded to 16 characters.
+const inviteCodeBytes = 12
+
+// SearchClubs returns up to limit discoverable clubs whose name contains the query.
+func (s *service) SearchClubs(ctx context.Context, query string, limit int) ([]Club, error) {
+	clubs, err := s.repo.SearchClubs(ctx, query, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search Clubs")
+	}
+
+	return clubs, nil
+}
+
+func (s *service) SetDiscoverable(ctx context.Context, id uint, discoverable bool) error {
+	if err := s.repo.SetDiscoverable(ctx, id, discoverable); err != nil {
+		return errors.Wrap(err, "failed to set whether Club is discoverable")
+	}
+
+	return nil
+}
+
+// CreateInviteLink creates a link to join the club, which can be used maxUses times, or any number of times if 0.
+// Without an expiry the link expires as an invite would.
+func (s *service) CreateInviteLink(ctx context.Context, clubId uint, createdBy uint, maxUses int, expiresAt *time.Time) (*InviteLink, error) {
+	now := time.Now()
+
+	expiry := now.Add(s.inviteExpiry)
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, ErrInvalidExpiry
+		}
+
+		expiry = *expiresAt
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate invite code")
+	}
+
+	link := &InviteLink{
+		ClubId:    clubId,
+		Code:      code,
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+		ExpiresAt: expiry,
+	}
+
+	if err := s.repo.CreateInviteLink(ctx, link); err != nil {
+		return nil, errors.Wrap(err, "failed to create invite link")
+	}
+
+	return link, nil
+}
+
+func (s *service) GetInviteLinksInClub(ctx context.Context, clubId uint) ([]InviteLink, error) {
+	links, err := s.repo.GetInviteLinksInClub(ctx, clubId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get invite links in Club %d", clubId)
+	}
+
+	return links, nil
+}
+
+// RevokeInviteLink stops the link from being used to join the club.
+func (s *service) RevokeInviteLink(ctx context.Context, clubId uint, linkId uint) error {
+	link, err := s.repo.GetInviteLink(ctx, linkId)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get invite link %d", linkId)
+	}
+
+	if link.ClubId != clubId {
+		return errors.Wrapf(ErrNotFound, "invite link %d in Club %d", linkId, clubId)
+	}
+
+	if link.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	link.RevokedAt = &now
+	if err := s.repo.UpdateInviteLink(ctx, link); err != nil {
+		return errors.Wrapf(err, "failed to revoke invite link %d", linkId)
+	}
+
+	return nil
+}
+
+// JoinWithInviteLink makes the user a member of the club the code links to, and returns the club.
+func (s *service) JoinWithInviteLink(ctx context.Context, userId uint, code string) (uint, error) {
+	var clubId uint
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		link, err := s.repo.GetInviteLinkByCodeForUpdate(ctx, code)
+		if err != nil {
+			return errors.Wrap(err, "failed to get invite link")
+		}
+
+		if !link.Usable(time.Now()) {
+			return ErrLinkNotUsable
+		}
+
+		if err := s.join(ctx, userId, link.ClubId); err != nil {
+			return err
+		}
+
+		link.Uses++
+		if err := s.repo.UpdateInviteLink(ctx, link); err != nil {
+			return errors.Wrapf(err, "failed to count use of invite link %d", link.Id)
+		}
+
+		clubId = link.ClubId
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return clubId, nil
+}
+
+// RequestToJoin asks the managers of a discoverable club to let the user join.
+// Clubs which are not discoverable are not found, so their existence is not given away.
+func (s *service) RequestToJoin(ctx context.Context, userId uint, clubId uint, message string) error {
+	club, err := s.repo.GetClub(ctx, clubId)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get Club %d", clubId)
+	}
+
+	if !club.Discoverable {
+		return errors.Wrapf(ErrNotFound, "Club %d is not discoverable", clubId)
+	}
+
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		_, err := s.repo.GetMembership(ctx, userId, clubId)
+		if err == nil {
+			return ErrAlreadyMember
+		}
+
+		if !errors.Is(err, ErrNotFound) {
+			return errors.Wrapf(err, "failed to get membership of user %d in Club %d", userId, clubId)
+		}
+
+		_, err = s.repo.GetPendingJoinRequest(ctx, userId, clubId)
+		if err == nil {
+			return ErrJoinRequestPending
+		}
+
+		if !errors.Is(err, ErrNotFound) {
+			return errors.Wrapf(err, "failed to get join request of user %d in Club %d", userId, clubId)
+		}
+
+		request := &JoinRequest{
+			ClubId:  clubId,
+			UserId:  userId,
+			Message: message,
+			Status:  StatusPending,
+		}
+
+		if err := s.repo.CreateJoinRequest(ctx, request); err != nil {
+			return errors.Wrap(err, "failed to create join request")
+		}
+
+		return nil
+	})
+}
+
+// GetJoinRequestsInClub returns every request to join the club, latest first.
+func (s *service) GetJoinRequestsInClub(ctx context.Context, clubId uint) ([]JoinRequest, error) {
+	requests, err := s.repo.GetJoinRequestsInClub(ctx, clubId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get join requests in Club %d", clubId)
+	}
+
+	return requests, nil
+}
+
+// ReviewJoinRequest approves or declines a request to join the club, approving makes the user a member.
+func (s *service) ReviewJoinRequest(ctx context.Context, clubId uint, requestId uint, reviewerId uint, approve bool) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		request, err := s.repo.GetJoinRequestForUpdate(ctx, requestId)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get join request %d", requestId)
+		}
+
+		if request.ClubId != clubId {
+			return errors.Wrapf(ErrNotFound, "join request %d in Club %d", requestId, clubId)
+		}
+
+		if request.Status != StatusPending {
+			return ErrJoinRequestNotPending
+		}
+
+		request.Status = StatusDeclined
+		if approve {
+			request.Status = StatusAccepted
+		}
+
+		request.ReviewedBy = reviewerId
+		if err := s.repo.UpdateJoinRequest(ctx, request); err != nil {
+			return errors.Wrapf(err, "failed to review join request %d", requestId)
+		}
+
+		// The user may have joined another way since asking, which approving does not need to undo.
+		if approve {
+			if err := s.join(ctx, request.UserId, clubId); err != nil && !errors.Is(err, ErrAlreadyMember) {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// join makes the user a member of the club, taking over any invite they had to it.
+func (s *service) join(ctx context.Context, userId uint, clubId uint) error {
+	latest, err := s.repo.GetLatestClubUser(ctx, userId, clubId)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if err := s.repo.AddUserToClub(ctx, userId, clubId, MemberRole); err != nil {
+			return errors.Wrapf(err, "failed to add user %d to Club %d", userId, clubId)
+		}
+	case err != nil:
+		return errors.Wrapf(err, "failed to get membership of user %d in Club %d", userId, clubId)
+	case latest.Status == StatusAccepted:
+		return ErrAlreadyMember
+	default:
+		latest.Status = StatusAccepted
+		latest.Role = MemberRole
+		if err := s.repo.UpdateInvite(ctx, latest); err != nil {
+			return errors.Wrapf(err, "failed to add user %d to Club %d", userId, clubId)
+		}
+	}
+
+	s.publishMembersChanged(ctx, clubId)
+
+	return nil
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, inviteCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
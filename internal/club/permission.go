@@ -0,0 +1,81 @@
+package club
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrForbidden = errors.New("forbidden")
+	ErrLastAdmin = errors.New("club must keep an admin")
+)
+
+// Action is something done in a club, which only some roles are allowed to do.
+type Action string
+
+const (
+	// ActionView covers reading the members, matches, teams, games and leaderboards of the club.
+	ActionView            Action = "view"
+	ActionRecordMatches   Action = "record-matches"
+	ActionModerateMatches Action = "moderate-matches"
+	ActionImportMatches   Action = "import-matches"
+	// ActionInvite covers sending invites and seeing those sent.
+	ActionInvite       Action = "invite"
+	ActionRevokeInvite Action = "revoke-invite"
+	// ActionManageInviteLinks covers creating, seeing and revoking links anyone can join the club with.
+	ActionManageInviteLinks  Action = "manage-invite-links"
+	ActionReviewJoinRequests Action = "review-join-requests"
+	ActionManageGames        Action = "manage-games"
+	ActionRemoveMember       Action = "remove-member"
+	ActionUpdateRole         Action = "update-role"
+	ActionUpdateClub         Action = "update-club"
+	ActionDeleteClub         Action = "delete-club"
+)
+
+// permissions lists the roles allowed to do each action. Actions missing from it are not allowed to anyone.
+var permissions = map[Action][]Role{
+	ActionView:               {AdminRole, ManagerRole, MemberRole},
+	ActionRecordMatches:      {AdminRole, ManagerRole, MemberRole},
+	ActionModerateMatches:    {AdminRole, ManagerRole},
+	ActionInvite:             {AdminRole, ManagerRole},
+	ActionManageGames:        {AdminRole, ManagerRole},
+	ActionReviewJoinRequests: {AdminRole, ManagerRole},
+	ActionImportMatches:      {AdminRole},
+	ActionRevokeInvite:       {AdminRole},
+	ActionManageInviteLinks:  {AdminRole},
+	ActionRemoveMember:       {AdminRole},
+	ActionUpdateRole:         {AdminRole},
+	ActionUpdateClub:         {AdminRole},
+	ActionDeleteClub:         {AdminRole},
+}
+
+// Can reports whether the role is allowed to do the action.
+func (r Role) Can(action Action) bool {
+	for _, role := range permissions[action] {
+		if role == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authorize returns the membership of the user in the club if their role allows the action,
+// and ErrForbidden if it does not or they are not a member.
+func (s *service) Authorize(ctx context.Context, userId uint, clubId uint, action Action) (*ClubsUsers, error) {
+	membership, err := s.repo.GetMembership(ctx, userId, clubId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, errors.Wrapf(ErrForbidden, "user %d is not in Club %d", userId, clubId)
+		}
+
+		return nil, errors.Wrapf(err, "failed to get membership of user %d in Club %d", userId, clubId)
+	}
+
+	if !membership.Role.Can(action) {
+		return nil, errors.Wrapf(ErrForbidden, "%s of user %d in Club %d can not %s", membership.Role, userId, clubId, action)
+	}
+
+	return membership, nil
+}
@@ -2,14 +2,21 @@ package club
 
 import (
 	"context"
+	"matchlog/pkg/database"
+	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const ErrCodeMySQLDuplicateEntry uint16 = 1062
 
+// likeEscaper escapes the wildcards of LIKE, so searches match them literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 var (
 	ErrDuplicateEntry = errors.New("already exists")
 	ErrNotFound       = errors.New("not found")
@@ -19,14 +26,36 @@ type Repository interface {
 	GetClub(ctx context.Context, id uint) (*Club, error)
 	GetClubs(ctx context.Context, ids []uint) ([]Club, error)
 	GetUserIdsInClub(ctx context.Context, id uint) ([]uint, error)
-	GetInvitesByUserId(ctx context.Context, userId uint) ([]ClubsUsers, error)
-	InviteToClub(ctx context.Context, userIds []uint, clubId uint) error
+	GetMembership(ctx context.Context, userId uint, clubId uint) (*ClubsUsers, error)
+	GetClubIdsOfUser(ctx context.Context, userId uint) ([]uint, error)
+	CountMembersWithRole(ctx context.Context, clubId uint, role Role) (int64, error)
+	GetLatestClubUser(ctx context.Context, userId uint, clubId uint) (*ClubsUsers, error)
+	GetInvite(ctx context.Context, id uint) (*ClubsUsers, error)
+	GetInvitesByUserId(ctx context.Context, userId uint, now time.Time) ([]ClubsUsers, error)
+	GetInvitesInClub(ctx context.Context, clubId uint) ([]ClubsUsers, error)
+	UpdateInvite(ctx context.Context, invite *ClubsUsers) error
+	GetGameIdsInClub(ctx context.Context, id uint) ([]uint, error)
+	GetAllClubsGames(ctx context.Context) ([]ClubsGames, error)
+	AddGameToClub(ctx context.Context, gameId uint, clubId uint) error
+	InviteToClub(ctx context.Context, userIds []uint, clubId uint, invitedBy uint, expiresAt time.Time) error
 	CreateClub(ctx context.Context, Club *Club) (clubId uint, err error)
 	AddUserToClub(ctx context.Context, userId uint, clubId uint, role Role) error
 	RemoveUserFromClub(ctx context.Context, userId uint, clubId uint) error
 	DeleteClub(ctx context.Context, id uint) error
 	UpdateClub(ctx context.Context, id uint, name string) error
 	UpdateUserRole(ctx context.Context, userId uint, clubId uint, role Role) error
+	SearchClubs(ctx context.Context, query string, limit int) ([]Club, error)
+	SetDiscoverable(ctx context.Context, id uint, discoverable bool) error
+	CreateInviteLink(ctx context.Context, link *InviteLink) error
+	GetInviteLink(ctx context.Context, id uint) (*InviteLink, error)
+	GetInviteLinkByCodeForUpdate(ctx context.Context, code string) (*InviteLink, error)
+	GetInviteLinksInClub(ctx context.Context, clubId uint) ([]InviteLink, error)
+	UpdateInviteLink(ctx context.Context, link *InviteLink) error
+	CreateJoinRequest(ctx context.Context, request *JoinRequest) error
+	GetJoinRequestForUpdate(ctx context.Context, id uint) (*JoinRequest, error)
+	GetPendingJoinRequest(ctx context.Context, userId uint, clubId uint) (*JoinRequest, error)
+	GetJoinRequestsInClub(ctx context.Context, clubId uint) ([]JoinRequest, error)
+	UpdateJoinRequest(ctx context.Context, request *JoinRequest) error
 }
 
 type repository struct {
@@ -41,7 +70,7 @@ func NewRepository(db *gorm.DB) Repository {
 
 func (r *repository) GetClub(ctx context.Context, id uint) (*Club, error) {
 	var club Club
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		First(&club, id)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -56,7 +85,7 @@ func (r *repository) GetClub(ctx context.Context, id uint) (*Club, error) {
 
 func (r *repository) GetClubs(ctx context.Context, ids []uint) ([]Club, error) {
 	var clubs []Club
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Find(&clubs, ids)
 	if result.Error != nil {
 		return nil, result.Error
@@ -67,8 +96,8 @@ func (r *repository) GetClubs(ctx context.Context, ids []uint) ([]Club, error) {
 
 func (r *repository) GetUserIdsInClub(ctx context.Context, id uint) ([]uint, error) {
 	var clubUsers []ClubsUsers
-	result := r.db.WithContext(ctx).
-		Where("Club_id = ?", id).
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND status = ?", id, StatusAccepted).
 		Find(&clubUsers)
 	if result.Error != nil {
 		return nil, result.Error
@@ -82,10 +111,89 @@ func (r *repository) GetUserIdsInClub(ctx context.Context, id uint) ([]uint, err
 	return userIds, nil
 }
 
-func (r *repository) GetInvitesByUserId(ctx context.Context, userId uint) ([]ClubsUsers, error) {
+func (r *repository) GetMembership(ctx context.Context, userId uint, clubId uint) (*ClubsUsers, error) {
+	var clubUser ClubsUsers
+	result := database.Conn(ctx, r.db).
+		Where("user_id = ? AND club_id = ? AND status = ?", userId, clubId, StatusAccepted).
+		First(&clubUser)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &clubUser, nil
+}
+
+func (r *repository) GetClubIdsOfUser(ctx context.Context, userId uint) ([]uint, error) {
+	var clubIds []uint
+	result := database.Conn(ctx, r.db).
+		Model(&ClubsUsers{}).
+		Where("user_id = ? AND status = ?", userId, StatusAccepted).
+		Distinct().
+		Pluck("club_id", &clubIds)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return clubIds, nil
+}
+
+func (r *repository) CountMembersWithRole(ctx context.Context, clubId uint, role Role) (int64, error) {
+	var count int64
+	result := database.Conn(ctx, r.db).
+		Model(&ClubsUsers{}).
+		Where("club_id = ? AND role = ? AND status = ?", clubId, role, StatusAccepted).
+		Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// GetLatestClubUser returns the latest membership or invite of the user in the club, whatever its status.
+func (r *repository) GetLatestClubUser(ctx context.Context, userId uint, clubId uint) (*ClubsUsers, error) {
+	var clubUser ClubsUsers
+	result := database.Conn(ctx, r.db).
+		Where("user_id = ? AND club_id = ?", userId, clubId).
+		Order("id desc").
+		First(&clubUser)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &clubUser, nil
+}
+
+func (r *repository) GetInvite(ctx context.Context, id uint) (*ClubsUsers, error) {
+	var invite ClubsUsers
+	result := database.Conn(ctx, r.db).
+		Where("id = ? AND status <> ?", id, StatusAccepted).
+		First(&invite)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &invite, nil
+}
+
+// GetInvitesByUserId returns the invites the user can still respond to.
+func (r *repository) GetInvitesByUserId(ctx context.Context, userId uint, now time.Time) ([]ClubsUsers, error) {
 	var clubUsers []ClubsUsers
-	result := r.db.WithContext(ctx).
-		Where("user_id = ? AND accepted = ?", userId, false).
+	result := database.Conn(ctx, r.db).
+		Where("user_id = ? AND status = ?", userId, StatusPending).
+		Where("(expires_at IS NULL OR expires_at > ?)", now).
 		Find(&clubUsers)
 	if result.Error != nil {
 		return nil, result.Error
@@ -94,8 +202,80 @@ func (r *repository) GetInvitesByUserId(ctx context.Context, userId uint) ([]Clu
 	return clubUsers, nil
 }
 
+// GetInvitesInClub returns every invite sent in the club which has not been accepted, latest first.
+func (r *repository) GetInvitesInClub(ctx context.Context, clubId uint) ([]ClubsUsers, error) {
+	var invites []ClubsUsers
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND status <> ?", clubId, StatusAccepted).
+		Order("id desc").
+		Find(&invites)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return invites, nil
+}
+
+func (r *repository) UpdateInvite(ctx context.Context, invite *ClubsUsers) error {
+	result := database.Conn(ctx, r.db).
+		Save(invite)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *repository) GetGameIdsInClub(ctx context.Context, id uint) ([]uint, error) {
+	var clubGames []ClubsGames
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ?", id).
+		Find(&clubGames)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var gameIds []uint
+	for _, clubGame := range clubGames {
+		gameIds = append(gameIds, clubGame.GameId)
+	}
+
+	return gameIds, nil
+}
+
+func (r *repository) GetAllClubsGames(ctx context.Context) ([]ClubsGames, error) {
+	var clubGames []ClubsGames
+	result := database.Conn(ctx, r.db).
+		Find(&clubGames)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return clubGames, nil
+}
+
+func (r *repository) AddGameToClub(ctx context.Context, gameId uint, clubId uint) error {
+	clubGame := &ClubsGames{
+		ClubId: clubId,
+		GameId: gameId,
+	}
+
+	result := database.Conn(ctx, r.db).
+		Create(&clubGame)
+	if result.Error != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(result.Error, &mysqlErr) && mysqlErr.Number == ErrCodeMySQLDuplicateEntry {
+			return ErrDuplicateEntry
+		}
+
+		return result.Error
+	}
+
+	return nil
+}
+
 func (r *repository) CreateClub(ctx context.Context, Club *Club) (uint, error) {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Create(&Club)
 	if result.Error != nil {
 		var mysqlErr *mysql.MySQLError
@@ -111,13 +291,13 @@ func (r *repository) CreateClub(ctx context.Context, Club *Club) (uint, error) {
 
 func (r *repository) AddUserToClub(ctx context.Context, userId uint, clubId uint, role Role) error {
 	clubUser := &ClubsUsers{
-		ClubId:   clubId,
-		UserId:   userId,
-		Accepted: true,
-		Role:     role,
+		ClubId: clubId,
+		UserId: userId,
+		Status: StatusAccepted,
+		Role:   role,
 	}
 
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Create(&clubUser)
 	if result.Error != nil {
 		return result.Error
@@ -127,7 +307,7 @@ func (r *repository) AddUserToClub(ctx context.Context, userId uint, clubId uint
 }
 
 func (r *repository) RemoveUserFromClub(ctx context.Context, userId uint, clubId uint) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Where("user_id = ? AND Club_id = ?", userId, clubId).
 		Delete(&ClubsUsers{})
 	if result.Error != nil {
@@ -138,7 +318,7 @@ func (r *repository) RemoveUserFromClub(ctx context.Context, userId uint, clubId
 }
 
 func (r *repository) DeleteClub(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Delete(&Club{}, id)
 	if result.Error != nil {
 		return result.Error
@@ -148,7 +328,7 @@ func (r *repository) DeleteClub(ctx context.Context, id uint) error {
 }
 
 func (r *repository) UpdateClub(ctx context.Context, id uint, name string) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Model(&Club{}).
 		Where("id = ?", id).
 		Update("name", name)
@@ -160,7 +340,7 @@ func (r *repository) UpdateClub(ctx context.Context, id uint, name string) error
 }
 
 func (r *repository) UpdateUserRole(ctx context.Context, userId uint, clubId uint, role Role) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Model(&ClubsUsers{}).
 		Where("user_id = ? AND Club_id = ?", userId, clubId).
 		Update("role", role)
@@ -171,18 +351,20 @@ func (r *repository) UpdateUserRole(ctx context.Context, userId uint, clubId uin
 	return nil
 }
 
-func (r *repository) InviteToClub(ctx context.Context, userIds []uint, clubId uint) error {
+func (r *repository) InviteToClub(ctx context.Context, userIds []uint, clubId uint, invitedBy uint, expiresAt time.Time) error {
 	var clubUsers []ClubsUsers
 	for _, userId := range userIds {
 		clubUsers = append(clubUsers, ClubsUsers{
-			ClubId:   clubId,
-			UserId:   userId,
-			Accepted: false,
-			Role:     MemberRole,
+			ClubId:    clubId,
+			UserId:    userId,
+			Status:    StatusPending,
+			Role:      MemberRole,
+			InvitedBy: invitedBy,
+			ExpiresAt: &expiresAt,
 		})
 	}
 
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Create(&clubUsers)
 	if result.Error != nil {
 		return result.Error
@@ -190,3 +372,171 @@ func (r *repository) InviteToClub(ctx context.Context, userIds []uint, clubId ui
 
 	return nil
 }
+
+// SearchClubs returns the discoverable clubs whose name contains the query, by name.
+func (r *repository) SearchClubs(ctx context.Context, query string, limit int) ([]Club, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	var clubs []Club
+	result := database.Conn(ctx, r.db).
+		Where("discoverable = ? AND name LIKE ?", true, pattern).
+		Order("name asc, id asc").
+		Limit(limit).
+		Find(&clubs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return clubs, nil
+}
+
+func (r *repository) SetDiscoverable(ctx context.Context, id uint, discoverable bool) error {
+	result := database.Conn(ctx, r.db).
+		Model(&Club{}).
+		Where("id = ?", id).
+		Update("discoverable", discoverable)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *repository) CreateInviteLink(ctx context.Context, link *InviteLink) error {
+	result := database.Conn(ctx, r.db).
+		Create(link)
+	if result.Error != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(result.Error, &mysqlErr) && mysqlErr.Number == ErrCodeMySQLDuplicateEntry {
+			return ErrDuplicateEntry
+		}
+
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *repository) GetInviteLink(ctx context.Context, id uint) (*InviteLink, error) {
+	var link InviteLink
+	result := database.Conn(ctx, r.db).
+		First(&link, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &link, nil
+}
+
+// GetInviteLinkByCodeForUpdate locks the link until the surrounding transaction ends, so its uses are counted one at a time.
+func (r *repository) GetInviteLinkByCodeForUpdate(ctx context.Context, code string) (*InviteLink, error) {
+	var link InviteLink
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", code).
+		First(&link)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &link, nil
+}
+
+// GetInviteLinksInClub returns every invite link of the club, latest first.
+func (r *repository) GetInviteLinksInClub(ctx context.Context, clubId uint) ([]InviteLink, error) {
+	var links []InviteLink
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ?", clubId).
+		Order("id desc").
+		Find(&links)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return links, nil
+}
+
+func (r *repository) UpdateInviteLink(ctx context.Context, link *InviteLink) error {
+	result := database.Conn(ctx, r.db).
+		Save(link)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *repository) CreateJoinRequest(ctx context.Context, request *JoinRequest) error {
+	result := database.Conn(ctx, r.db).
+		Create(request)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetJoinRequestForUpdate locks the request until the surrounding transaction ends, so it is reviewed only once.
+func (r *repository) GetJoinRequestForUpdate(ctx context.Context, id uint) (*JoinRequest, error) {
+	var request JoinRequest
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&request, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &request, nil
+}
+
+func (r *repository) GetPendingJoinRequest(ctx context.Context, userId uint, clubId uint) (*JoinRequest, error) {
+	var request JoinRequest
+	result := database.Conn(ctx, r.db).
+		Where("user_id = ? AND club_id = ? AND status = ?", userId, clubId, StatusPending).
+		First(&request)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &request, nil
+}
+
+// GetJoinRequestsInClub returns every request to join the club, latest first.
+func (r *repository) GetJoinRequestsInClub(ctx context.Context, clubId uint) ([]JoinRequest, error) {
+	var requests []JoinRequest
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ?", clubId).
+		Order("id desc").
+		Find(&requests)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return requests, nil
+}
+
+func (r *repository) UpdateJoinRequest(ctx context.Context, request *JoinRequest) error {
+	result := database.Conn(ctx, r.db).
+		Save(request)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
@@ -2,30 +2,68 @@ package club
 
 import (
 	"context"
+	"matchlog/pkg/database"
+	"matchlog/pkg/pubsub"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+var (
+	ErrInviteExpired    = errors.New("invite has expired")
+	ErrInviteNotPending = errors.New("invite has already been responded to or revoked")
+)
+
 type Service interface {
 	GetClub(ctx context.Context, id uint) (*Club, error)
 	GetClubs(ctx context.Context, ids []uint) ([]Club, error)
 	GetUserIdsInClub(ctx context.Context, id uint) ([]uint, error)
+	GetMembership(ctx context.Context, userId uint, clubId uint) (*ClubsUsers, error)
+	GetSharedClubIds(ctx context.Context, userIds []uint) ([]uint, error)
+	Authorize(ctx context.Context, userId uint, clubId uint, action Action) (*ClubsUsers, error)
 	GetInvitesByUserId(ctx context.Context, userId uint) ([]ClubsUsers, error)
-	InviteToClub(ctx context.Context, userIds []uint, clubId uint) error
+	GetInvitesInClub(ctx context.Context, clubId uint) ([]ClubsUsers, error)
+	RespondToInvite(ctx context.Context, userId uint, inviteId uint, accept bool) error
+	RevokeInvite(ctx context.Context, clubId uint, inviteId uint) error
+	GetGameIdsInClub(ctx context.Context, id uint) ([]uint, error)
+	GetAllClubsGames(ctx context.Context) ([]ClubsGames, error)
+	AddGameToClub(ctx context.Context, gameId uint, clubId uint) error
+	InviteToClub(ctx context.Context, userIds []uint, clubId uint, invitedBy uint) error
 	CreateClub(ctx context.Context, name string, adminUserId uint) (clubId uint, err error)
+	AddUserToClub(ctx context.Context, userId uint, clubId uint, role Role) error
 	RemoveUserFromClub(ctx context.Context, userId uint, clubId uint) error
 	DeleteClub(ctx context.Context, id uint) error
 	UpdateClub(ctx context.Context, id uint, name string) error
 	UpdateUserRole(ctx context.Context, userId uint, clubId uint, role Role) error
+	SubscribeToMembers(clubId uint, buffer int) (changes <-chan MembersChanged, unsubscribe func())
+	OnMembersChanged(hook func(MembersChanged))
+	SearchClubs(ctx context.Context, query string, limit int) ([]Club, error)
+	SetDiscoverable(ctx context.Context, id uint, discoverable bool) error
+	CreateInviteLink(ctx context.Context, clubId uint, createdBy uint, maxUses int, expiresAt *time.Time) (*InviteLink, error)
+	GetInviteLinksInClub(ctx context.Context, clubId uint) ([]InviteLink, error)
+	RevokeInviteLink(ctx context.Context, clubId uint, linkId uint) error
+	JoinWithInviteLink(ctx context.Context, userId uint, code string) (clubId uint, err error)
+	RequestToJoin(ctx context.Context, userId uint, clubId uint, message string) error
+	GetJoinRequestsInClub(ctx context.Context, clubId uint) ([]JoinRequest, error)
+	ReviewJoinRequest(ctx context.Context, clubId uint, requestId uint, reviewerId uint, approve bool) error
 }
 
 type service struct {
-	repo Repository
+	repo       Repository
+	transactor database.Transactor
+
+	// inviteExpiry is how long an invite can be responded to after it is sent.
+	inviteExpiry time.Duration
+
+	members *pubsub.Broker[MembersChanged]
 }
 
-func NewService(repo Repository) Service {
+func NewService(repo Repository, transactor database.Transactor, inviteExpiry time.Duration) Service {
 	return &service{
-		repo: repo,
+		repo:         repo,
+		transactor:   transactor,
+		inviteExpiry: inviteExpiry,
+		members:      pubsub.NewBroker[MembersChanged](),
 	}
 }
 
@@ -56,8 +94,50 @@ func (s *service) GetUserIdsInClub(ctx context.Context, id uint) ([]uint, error)
 	return userIds, nil
 }
 
+func (s *service) GetMembership(ctx context.Context, userId uint, clubId uint) (*ClubsUsers, error) {
+	membership, err := s.repo.GetMembership(ctx, userId, clubId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get membership of user %d in Club %d", userId, clubId)
+	}
+
+	return membership, nil
+}
+
+// GetSharedClubIds returns the clubs every one of the users is a member of.
+func (s *service) GetSharedClubIds(ctx context.Context, userIds []uint) ([]uint, error) {
+	var shared []uint
+	for i, userId := range userIds {
+		clubIds, err := s.repo.GetClubIdsOfUser(ctx, userId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get Clubs of user %d", userId)
+		}
+
+		if i == 0 {
+			shared = clubIds
+			continue
+		}
+
+		member := make(map[uint]bool, len(clubIds))
+		for _, clubId := range clubIds {
+			member[clubId] = true
+		}
+
+		kept := shared[:0]
+		for _, clubId := range shared {
+			if member[clubId] {
+				kept = append(kept, clubId)
+			}
+		}
+
+		shared = kept
+	}
+
+	return shared, nil
+}
+
+// GetInvitesByUserId returns the invites the user can still respond to.
 func (s *service) GetInvitesByUserId(ctx context.Context, userId uint) ([]ClubsUsers, error) {
-	invites, err := s.repo.GetInvitesByUserId(ctx, userId)
+	invites, err := s.repo.GetInvitesByUserId(ctx, userId, time.Now())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get user invites")
 	}
@@ -65,36 +145,168 @@ func (s *service) GetInvitesByUserId(ctx context.Context, userId uint) ([]ClubsU
 	return invites, nil
 }
 
+// GetInvitesInClub returns every invite sent in the club which has not been accepted, latest first.
+func (s *service) GetInvitesInClub(ctx context.Context, clubId uint) ([]ClubsUsers, error) {
+	invites, err := s.repo.GetInvitesInClub(ctx, clubId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get invites in Club %d", clubId)
+	}
+
+	return invites, nil
+}
+
+// RespondToInvite accepts or declines an invite sent to the user.
+// Invites sent to other users are not found, so their existence is not given away.
+func (s *service) RespondToInvite(ctx context.Context, userId uint, inviteId uint, accept bool) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		invite, err := s.repo.GetInvite(ctx, inviteId)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get invite %d", inviteId)
+		}
+
+		if invite.UserId != userId {
+			return errors.Wrapf(ErrNotFound, "invite %d", inviteId)
+		}
+
+		switch invite.CurrentStatus(time.Now()) {
+		case StatusPending:
+		case StatusExpired:
+			return ErrInviteExpired
+		default:
+			return ErrInviteNotPending
+		}
+
+		invite.Status = StatusDeclined
+		if accept {
+			invite.Status = StatusAccepted
+		}
+
+		if err := s.repo.UpdateInvite(ctx, invite); err != nil {
+			return errors.Wrapf(err, "failed to respond to invite %d", inviteId)
+		}
+
+		if accept {
+			s.publishMembersChanged(ctx, invite.ClubId)
+		}
+
+		return nil
+	})
+}
+
+// RevokeInvite withdraws an invite sent in the club, which can then no longer be accepted.
+func (s *service) RevokeInvite(ctx context.Context, clubId uint, inviteId uint) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		invite, err := s.repo.GetInvite(ctx, inviteId)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get invite %d", inviteId)
+		}
+
+		if invite.ClubId != clubId {
+			return errors.Wrapf(ErrNotFound, "invite %d in Club %d", inviteId, clubId)
+		}
+
+		if invite.Status != StatusPending {
+			return ErrInviteNotPending
+		}
+
+		invite.Status = StatusRevoked
+		if err := s.repo.UpdateInvite(ctx, invite); err != nil {
+			return errors.Wrapf(err, "failed to revoke invite %d", inviteId)
+		}
+
+		return nil
+	})
+}
+
+func (s *service) GetGameIdsInClub(ctx context.Context, id uint) ([]uint, error) {
+	gameIds, err := s.repo.GetGameIdsInClub(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get gameIds in Club")
+	}
+
+	return gameIds, nil
+}
+
+// GetAllClubsGames returns every game played in every club.
+func (s *service) GetAllClubsGames(ctx context.Context) ([]ClubsGames, error) {
+	clubGames, err := s.repo.GetAllClubsGames(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get games of all Clubs")
+	}
+
+	return clubGames, nil
+}
+
+func (s *service) AddGameToClub(ctx context.Context, gameId uint, clubId uint) error {
+	if err := s.repo.AddGameToClub(ctx, gameId, clubId); err != nil {
+		return errors.Wrap(err, "failed to add game to Club")
+	}
+
+	return nil
+}
+
 func (s *service) CreateClub(ctx context.Context, name string, adminUserId uint) (uint, error) {
 	club := &Club{
 		Name: name,
 	}
 
-	clubId, err := s.repo.CreateClub(ctx, club)
-	if err != nil {
-		return 0, errors.Wrap(err, "failed to create Club")
-	}
+	var clubId uint
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateClub(ctx, club)
+		if err != nil {
+			return errors.Wrap(err, "failed to create Club")
+		}
+
+		if err := s.repo.AddUserToClub(ctx, adminUserId, id, AdminRole); err != nil {
+			return errors.Wrap(err, "failed to add creating user to Club")
+		}
 
-	if err := s.repo.AddUserToClub(ctx, adminUserId, clubId, AdminRole); err != nil {
-		return 0, errors.Wrap(err, "failed to add creating user to Club")
+		clubId = id
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
 	return clubId, nil
 }
 
-func (s *service) RemoveUserFromClub(ctx context.Context, userId uint, clubId uint) error {
-	if err := s.repo.RemoveUserFromClub(ctx, userId, clubId); err != nil {
-		return errors.Wrap(err, "failed to remove user from Club")
+// AddUserToClub adds the user as an accepted member, without an invite.
+func (s *service) AddUserToClub(ctx context.Context, userId uint, clubId uint, role Role) error {
+	if err := s.repo.AddUserToClub(ctx, userId, clubId, role); err != nil {
+		return errors.Wrap(err, "failed to add user to Club")
 	}
 
+	s.publishMembersChanged(ctx, clubId)
+
 	return nil
 }
 
+// RemoveUserFromClub removes the user from the club, unless they are its last admin.
+func (s *service) RemoveUserFromClub(ctx context.Context, userId uint, clubId uint) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if err := s.ensureAnotherAdmin(ctx, userId, clubId); err != nil {
+			return err
+		}
+
+		if err := s.repo.RemoveUserFromClub(ctx, userId, clubId); err != nil {
+			return errors.Wrap(err, "failed to remove user from Club")
+		}
+
+		s.publishMembersChanged(ctx, clubId)
+
+		return nil
+	})
+}
+
 func (s *service) DeleteClub(ctx context.Context, id uint) error {
 	if err := s.repo.DeleteClub(ctx, id); err != nil {
 		return errors.Wrap(err, "failed to delete Club")
 	}
 
+	s.publishMembersChanged(ctx, id)
+
 	return nil
 }
 
@@ -106,18 +318,108 @@ func (s *service) UpdateClub(ctx context.Context, id uint, name string) error {
 	return nil
 }
 
+// UpdateUserRole changes the role of the user in the club, unless it would leave the club without an admin.
 func (s *service) UpdateUserRole(ctx context.Context, userId uint, clubId uint, role Role) error {
-	if err := s.repo.UpdateUserRole(ctx, userId, clubId, role); err != nil {
-		return errors.Wrap(err, "failed to update user role")
-	}
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		if role != AdminRole {
+			if err := s.ensureAnotherAdmin(ctx, userId, clubId); err != nil {
+				return err
+			}
+		}
 
-	return nil
+		if err := s.repo.UpdateUserRole(ctx, userId, clubId, role); err != nil {
+			return errors.Wrap(err, "failed to update user role")
+		}
+
+		return nil
+	})
 }
 
-func (s *service) InviteToClub(ctx context.Context, userIds []uint, clubId uint) error {
-	if err := s.repo.InviteToClub(ctx, userIds, clubId); err != nil {
-		return errors.Wrap(err, "failed to invite users to club")
+// ensureAnotherAdmin returns ErrLastAdmin if the user is the only admin of the club.
+func (s *service) ensureAnotherAdmin(ctx context.Context, userId uint, clubId uint) error {
+	membership, err := s.repo.GetMembership(ctx, userId, clubId)
+	if errors.Is(err, ErrNotFound) || (err == nil && membership.Role != AdminRole) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to get membership of user %d in Club %d", userId, clubId)
+	}
+
+	admins, err := s.repo.CountMembersWithRole(ctx, clubId, AdminRole)
+	if err != nil {
+		return errors.Wrap(err, "failed to count admins of Club")
+	}
+
+	if admins <= 1 {
+		return ErrLastAdmin
 	}
 
 	return nil
 }
+
+// InviteToClub invites the users who are neither members nor already invited to the club.
+// Users who declined, or whose invite expired or was revoked, are invited again.
+func (s *service) InviteToClub(ctx context.Context, userIds []uint, clubId uint, invitedBy uint) error {
+	now := time.Now()
+	expiresAt := now.Add(s.inviteExpiry)
+
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		var newUserIds []uint
+		for _, userId := range userIds {
+			latest, err := s.repo.GetLatestClubUser(ctx, userId, clubId)
+			if errors.Is(err, ErrNotFound) {
+				newUserIds = append(newUserIds, userId)
+				continue
+			}
+
+			if err != nil {
+				return errors.Wrapf(err, "failed to get membership of user %d in Club %d", userId, clubId)
+			}
+
+			status := latest.CurrentStatus(now)
+			if status == StatusAccepted || status == StatusPending {
+				continue
+			}
+
+			latest.Status = StatusPending
+			latest.Role = MemberRole
+			latest.InvitedBy = invitedBy
+			latest.ExpiresAt = &expiresAt
+			if err := s.repo.UpdateInvite(ctx, latest); err != nil {
+				return errors.Wrapf(err, "failed to invite user %d to Club %d again", userId, clubId)
+			}
+		}
+
+		if len(newUserIds) == 0 {
+			return nil
+		}
+
+		if err := s.repo.InviteToClub(ctx, newUserIds, clubId, invitedBy, expiresAt); err != nil {
+			return errors.Wrap(err, "failed to invite users to club")
+		}
+
+		return nil
+	})
+}
+
+// SubscribeToMembers returns a channel receiving the changes to who is in the club,
+// holding up to buffer changes that have not been picked up. A club of 0 receives the changes in every club.
+func (s *service) SubscribeToMembers(clubId uint, buffer int) (<-chan MembersChanged, func()) {
+	return s.members.Subscribe(func(change MembersChanged) bool {
+		return clubId == 0 || change.ClubId == clubId
+	}, buffer)
+}
+
+// OnMembersChanged runs the hook on every change to who is in a club as it is published,
+// before subscribers receive it.
+func (s *service) OnMembersChanged(hook func(MembersChanged)) {
+	s.members.OnPublish(hook)
+}
+
+// publishMembersChanged lets subscribers know about the change once the transaction making it commits.
+func (s *service) publishMembersChanged(ctx context.Context, clubId uint) {
+	database.AfterCommit(ctx, func() {
+		s.members.Publish(MembersChanged{ClubId: clubId})
+	})
+}
@@ -12,4 +12,21 @@ type Game struct {
 	Id   uint     `gorm:"primaryKey"`
 	Name string   `gorm:"not null"`
 	Type GameType `gorm:"not null"`
+
+	Rules
+}
+
+// Rules describe what a valid result of a game looks like.
+// Zero values mean there is no such rule, so a game without rules accepts any non-negative scores.
+type Rules struct {
+	// PointsToWinSet is the score a side needs to reach to win a set.
+	PointsToWinSet int `gorm:"not null"`
+	// WinByTwo requires the winner of a set to be two points ahead, extending the set past PointsToWinSet if necessary.
+	WinByTwo bool `gorm:"not null"`
+	// BestOf is the maximum number of sets in a match, which ends as soon as a side has won a majority of them.
+	BestOf int `gorm:"not null"`
+	// AllowDraws allows a match to end without a winner.
+	AllowDraws bool `gorm:"not null"`
+	// MaxScore caps the score of a set, ending a set that would otherwise go on with win-by-two.
+	MaxScore int `gorm:"not null"`
 }
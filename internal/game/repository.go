@@ -2,6 +2,7 @@ package game
 
 import (
 	"context"
+	"matchlog/pkg/database"
 
 	"gorm.io/gorm"
 )
@@ -24,7 +25,7 @@ func NewRepository(db *gorm.DB) *repository {
 
 func (r *repository) GetGame(ctx context.Context, id uint) (*Game, error) {
 	var game Game
-	result := r.db.WithContext(ctx).First(&game, id)
+	result := database.Conn(ctx, r.db).First(&game, id)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -34,7 +35,7 @@ func (r *repository) GetGame(ctx context.Context, id uint) (*Game, error) {
 
 func (r *repository) GetGames(ctx context.Context, ids []uint) ([]*Game, error) {
 	var games []*Game
-	result := r.db.WithContext(ctx).Find(&games, ids)
+	result := database.Conn(ctx, r.db).Find(&games, ids)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -43,7 +44,7 @@ func (r *repository) GetGames(ctx context.Context, ids []uint) ([]*Game, error)
 }
 
 func (r *repository) CreateGame(ctx context.Context, game *Game) error {
-	result := r.db.WithContext(ctx).Create(game)
+	result := database.Conn(ctx, r.db).Create(game)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -52,7 +53,7 @@ func (r *repository) CreateGame(ctx context.Context, game *Game) error {
 }
 
 func (r *repository) UpdateGame(ctx context.Context, game *Game) error {
-	result := r.db.WithContext(ctx).Save(game)
+	result := database.Conn(ctx, r.db).Save(game)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -61,7 +62,7 @@ func (r *repository) UpdateGame(ctx context.Context, game *Game) error {
 }
 
 func (r *repository) DeleteGame(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).Delete(&Game{}, id)
+	result := database.Conn(ctx, r.db).Delete(&Game{}, id)
 	if result.Error != nil {
 		return result.Error
 	}
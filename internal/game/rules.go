@@ -0,0 +1,118 @@
+package game
+
+import (
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidScores = errors.New("invalid scores")
+
+// ValidateSets checks that the scores of each set, and the sets together, are a possible result under the rules.
+func (r Rules) ValidateSets(scoresA, scoresB []int) error {
+	if len(scoresA) == 0 || len(scoresA) != len(scoresB) {
+		return errors.Wrap(ErrInvalidScores, "both sides need a score for every set")
+	}
+
+	if r.BestOf > 0 && len(scoresA) > r.BestOf {
+		return errors.Wrapf(ErrInvalidScores, "at most %d sets are played", r.BestOf)
+	}
+
+	setsToWin := r.BestOf/2 + 1
+
+	setsA, setsB := 0, 0
+	for i := range scoresA {
+		if r.BestOf > 0 && (setsA == setsToWin || setsB == setsToWin) {
+			return errors.Wrapf(ErrInvalidScores, "set %d is played after the match was decided", i+1)
+		}
+
+		if err := r.validateSet(scoresA[i], scoresB[i]); err != nil {
+			return errors.Wrapf(err, "set %d", i+1)
+		}
+
+		if scoresA[i] > scoresB[i] {
+			setsA++
+		} else if scoresB[i] > scoresA[i] {
+			setsB++
+		}
+	}
+
+	if r.AllowDraws {
+		return nil
+	}
+
+	if setsA == setsB {
+		return errors.Wrap(ErrInvalidScores, "the match has no winner")
+	}
+
+	if r.BestOf > 0 && setsA < setsToWin && setsB < setsToWin {
+		return errors.Wrapf(ErrInvalidScores, "neither side won %d sets", setsToWin)
+	}
+
+	return nil
+}
+
+func (r Rules) validateSet(scoreA, scoreB int) error {
+	if scoreA < 0 || scoreB < 0 {
+		return errors.Wrap(ErrInvalidScores, "scores can not be negative")
+	}
+
+	high, low := scoreA, scoreB
+	if low > high {
+		high, low = low, high
+	}
+
+	if r.MaxScore > 0 && high > r.MaxScore {
+		return errors.Wrapf(ErrInvalidScores, "%d-%d is above the maximum score of %d", scoreA, scoreB, r.MaxScore)
+	}
+
+	if r.PointsToWinSet == 0 {
+		return nil
+	}
+
+	switch {
+	case high < r.PointsToWinSet:
+		return errors.Wrapf(ErrInvalidScores, "%d-%d is not finished, a set is played to %d", scoreA, scoreB, r.PointsToWinSet)
+	case !r.WinByTwo && (high != r.PointsToWinSet || low == high):
+		return errors.Wrapf(ErrInvalidScores, "%d-%d is not possible in a set played to %d", scoreA, scoreB, r.PointsToWinSet)
+	case r.WinByTwo && high > r.PointsToWinSet && high == r.MaxScore && high-low == 1:
+		// Reaching the maximum score ends an extended set without a two point lead.
+		return nil
+	case r.WinByTwo && high == r.PointsToWinSet && high-low < 2:
+		return errors.Wrapf(ErrInvalidScores, "%d-%d is not finished, a set is won by two", scoreA, scoreB)
+	case r.WinByTwo && high > r.PointsToWinSet && high-low != 2:
+		return errors.Wrapf(ErrInvalidScores, "%d-%d is not possible in a set won by two", scoreA, scoreB)
+	}
+
+	return nil
+}
+
+// ValidatePlacements checks that the finishing places of a free-for-all match are possible under the rules.
+// Places start at 1 and players who tied share a place.
+func (r Rules) ValidatePlacements(placements []int) error {
+	if len(placements) < 2 {
+		return errors.Wrap(ErrInvalidScores, "a free-for-all match needs at least two players")
+	}
+
+	best, sharedBy := 0, 0
+	for _, placement := range placements {
+		if placement < 1 || placement > len(placements) {
+			return errors.Wrapf(ErrInvalidScores, "place %d is not possible with %d players", placement, len(placements))
+		}
+
+		switch {
+		case sharedBy == 0 || placement < best:
+			best, sharedBy = placement, 1
+		case placement == best:
+			sharedBy++
+		}
+	}
+
+	if best != 1 {
+		return errors.Wrap(ErrInvalidScores, "no player finished first")
+	}
+
+	if !r.AllowDraws && sharedBy > 1 {
+		return errors.Wrap(ErrInvalidScores, "the match has no winner")
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package importer
+
+import (
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// Record is a historical match as it appears in an import file.
+// Players are given by email, or by name for players without an account.
+type Record struct {
+	PlayedAt time.Time `json:"playedAt"`
+	TeamA    []string  `json:"teamA"`
+	TeamB    []string  `json:"teamB"`
+	// Sets are the scores of each set, formatted as "10-4".
+	Sets  []string `json:"sets"`
+	Rated *bool    `json:"rated"`
+}
+
+// Result describes what an import did.
+type Result struct {
+	Imported     int      `json:"imported"`
+	VirtualUsers []string `json:"virtualUsers"`
+}
@@ -0,0 +1,152 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidFile = errors.New("invalid import file")
+
+// timeLayouts are the timestamp formats accepted in import files, spreadsheets rarely export RFC 3339.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parse reads the records of an import file.
+//
+// A CSV file has a header with the columns played_at, team_a, team_b and sets, and optionally rated.
+// Players and sets are separated by semicolons, as in "alice@example.com;Bob" and "10-4;8-10;10-7".
+// A JSON file is an array of records.
+func Parse(r io.Reader, format Format) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSON:
+		return parseJSON(r)
+	default:
+		return nil, errors.Errorf("unsupported import format: %s", format)
+	}
+}
+
+func parseJSON(r io.Reader) ([]Record, error) {
+	var records []Record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, errors.Wrap(ErrInvalidFile, err.Error())
+	}
+
+	for i, record := range records {
+		if record.PlayedAt.IsZero() {
+			return nil, errors.Wrapf(ErrInvalidFile, "record %d has no playedAt", i+1)
+		}
+	}
+
+	return records, nil
+}
+
+func parseCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidFile, err.Error())
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.Wrap(ErrInvalidFile, "missing header")
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"played_at", "team_a", "team_b", "sets"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.Wrapf(ErrInvalidFile, "missing column %s", name)
+		}
+	}
+
+	records := make([]Record, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		line := i + 2
+
+		playedAt, err := parseTime(row[columns["played_at"]])
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidFile, "line %d: %s", line, err)
+		}
+
+		record := Record{
+			PlayedAt: playedAt,
+			TeamA:    split(row[columns["team_a"]]),
+			TeamB:    split(row[columns["team_b"]]),
+			Sets:     split(row[columns["sets"]]),
+		}
+
+		if column, ok := columns["rated"]; ok && strings.TrimSpace(row[column]) != "" {
+			rated, err := strconv.ParseBool(strings.TrimSpace(row[column]))
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidFile, "line %d: invalid rated %q", line, row[column])
+			}
+
+			record.Rated = &rated
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("invalid played_at %q", value)
+}
+
+func split(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
+
+// parseSets splits sets formatted as "10-4" into the scores of each side.
+func parseSets(sets []string) (scoresA, scoresB []int, err error) {
+	scoresA = make([]int, len(sets))
+	scoresB = make([]int, len(sets))
+	for i, set := range sets {
+		a, b, ok := strings.Cut(set, "-")
+		if !ok {
+			return nil, nil, errors.Wrapf(ErrInvalidFile, "invalid set %q", set)
+		}
+
+		if scoresA[i], err = strconv.Atoi(strings.TrimSpace(a)); err != nil {
+			return nil, nil, errors.Wrapf(ErrInvalidFile, "invalid set %q", set)
+		}
+
+		if scoresB[i], err = strconv.Atoi(strings.TrimSpace(b)); err != nil {
+			return nil, nil, errors.Wrapf(ErrInvalidFile, "invalid set %q", set)
+		}
+	}
+
+	return scoresA, scoresB, nil
+}
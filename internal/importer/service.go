@@ -0,0 +1,195 @@
+package importer
+
+import (
+	"context"
+	"matchlog/internal/club"
+	"matchlog/internal/match"
+	"matchlog/internal/user"
+	"matchlog/pkg/database"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrUnknownPlayer = errors.New("unknown player")
+
+type Service interface {
+	ImportMatches(ctx context.Context, clubId, gameId, importedBy uint, records []Record, dryRun bool) (*Result, error)
+}
+
+type ServiceImpl struct {
+	transactor   database.Transactor
+	userService  user.Service
+	clubService  club.Service
+	matchService match.Service
+}
+
+func NewService(transactor database.Transactor, userService user.Service, clubService club.Service, matchService match.Service) Service {
+	return &ServiceImpl{
+		transactor:   transactor,
+		userService:  userService,
+		clubService:  clubService,
+		matchService: matchService,
+	}
+}
+
+// errDryRun rolls back a dry run once everything has been validated.
+var errDryRun = errors.New("dry run")
+
+// ImportMatches imports historical matches of a game into a club.
+// Players given by email must already be members, players given by a name no member has are added as virtual users.
+// A dry run validates everything without keeping any of it.
+func (s *ServiceImpl) ImportMatches(ctx context.Context, clubId, gameId, importedBy uint, records []Record, dryRun bool) (*Result, error) {
+	result := &Result{
+		VirtualUsers: []string{},
+	}
+
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		players, err := s.newPlayerResolver(ctx, clubId)
+		if err != nil {
+			return err
+		}
+
+		submissions := make([]match.Submission, len(records))
+		for i, record := range records {
+			teamA, err := players.resolve(ctx, record.TeamA)
+			if err != nil {
+				return errors.Wrapf(err, "match %d", i+1)
+			}
+
+			teamB, err := players.resolve(ctx, record.TeamB)
+			if err != nil {
+				return errors.Wrapf(err, "match %d", i+1)
+			}
+
+			scoresA, scoresB, err := parseSets(record.Sets)
+			if err != nil {
+				return errors.Wrapf(err, "match %d", i+1)
+			}
+
+			rated := true
+			if record.Rated != nil {
+				rated = *record.Rated
+			}
+
+			submissions[i] = match.Submission{
+				SubmittedBy: importedBy,
+				ClubId:      clubId,
+				GameId:      gameId,
+				TeamA:       teamA,
+				TeamB:       teamB,
+				ScoresA:     scoresA,
+				ScoresB:     scoresB,
+				Rated:       rated,
+				PlayedAt:    record.PlayedAt,
+			}
+		}
+
+		if err := s.matchService.ImportMatches(ctx, submissions); err != nil {
+			return errors.Wrap(err, "failed to import matches")
+		}
+
+		result.Imported = len(submissions)
+		result.VirtualUsers = players.created
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// playerResolver maps the players of an import file to users, creating virtual users for names no member has.
+type playerResolver struct {
+	s       *ServiceImpl
+	clubId  uint
+	byEmail map[string]uint
+	byName  map[string][]uint
+	created []string
+}
+
+func (s *ServiceImpl) newPlayerResolver(ctx context.Context, clubId uint) (*playerResolver, error) {
+	userIds, err := s.clubService.GetUserIdsInClub(ctx, clubId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get users in club %d", clubId)
+	}
+
+	members, err := s.userService.GetUsers(ctx, userIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get members")
+	}
+
+	r := &playerResolver{
+		s:       s,
+		clubId:  clubId,
+		byEmail: map[string]uint{},
+		byName:  map[string][]uint{},
+		created: []string{},
+	}
+
+	for _, u := range members {
+		if u.Email != "" {
+			r.byEmail[strings.ToLower(u.Email)] = u.Id
+		}
+
+		name := strings.ToLower(u.Name)
+		r.byName[name] = append(r.byName[name], u.Id)
+	}
+
+	return r, nil
+}
+
+func (r *playerResolver) resolve(ctx context.Context, players []string) ([]uint, error) {
+	userIds := make([]uint, len(players))
+	for i, player := range players {
+		userId, err := r.resolvePlayer(ctx, player)
+		if err != nil {
+			return nil, err
+		}
+
+		userIds[i] = userId
+	}
+
+	return userIds, nil
+}
+
+func (r *playerResolver) resolvePlayer(ctx context.Context, player string) (uint, error) {
+	key := strings.ToLower(player)
+
+	if strings.Contains(player, "@") {
+		userId, ok := r.byEmail[key]
+		if !ok {
+			return 0, errors.Wrapf(ErrUnknownPlayer, "no member has the email %s", player)
+		}
+
+		return userId, nil
+	}
+
+	switch userIds := r.byName[key]; len(userIds) {
+	case 0:
+	case 1:
+		return userIds[0], nil
+	default:
+		return 0, errors.Wrapf(ErrUnknownPlayer, "more than one member is called %s, use an email instead", player)
+	}
+
+	userId, err := r.s.userService.CreateVirtualUser(ctx, player)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to create virtual user %s", player)
+	}
+
+	if err := r.s.clubService.AddUserToClub(ctx, userId, r.clubId, club.MemberRole); err != nil {
+		return 0, errors.Wrapf(err, "failed to add virtual user %s to club", player)
+	}
+
+	r.byName[key] = []uint{userId}
+	r.created = append(r.created, player)
+
+	return userId, nil
+}
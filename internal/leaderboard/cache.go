@@ -0,0 +1,140 @@
+package leaderboard
+
+import (
+	"expvar"
+	"matchlog/internal/team"
+	"sync"
+	"time"
+)
+
+// cacheTTL bounds how stale a cached leaderboard can get, should a change go unnoticed or a week pass by the reference point of its rank changes.
+const cacheTTL = 5 * time.Minute
+
+// cacheMetrics are exposed along with the other expvars at /debug/vars.
+var cacheMetrics = expvar.NewMap("leaderboard_cache")
+
+// cacheKey tells leaderboards apart by everything that goes into ranking them, but not by the page shown of them.
+type cacheKey struct {
+	clubId          uint
+	gameId          uint
+	leaderboardType LeaderboardType
+	minGames        int
+	from            time.Time
+	to              time.Time
+	since           Since
+}
+
+// ranking is every entry of a leaderboard, with ranks, names, rank changes and trends, along with the teams of a team leaderboard.
+type ranking struct {
+	entries []Entry
+	teams   map[uint]team.Team
+
+	expiresAt time.Time
+}
+
+// cache holds computed leaderboards until the standings or members of their club change.
+// Every invalidation moves the generation of the club on, so that a ranking computed before it is not cached after it.
+type cache struct {
+	mu          sync.Mutex
+	rankings    map[cacheKey]ranking
+	generations map[uint]uint64
+	// allGeneration moves on when every club is invalidated at once.
+	allGeneration uint64
+}
+
+func newCache() *cache {
+	return &cache{
+		rankings:    map[cacheKey]ranking{},
+		generations: map[uint]uint64{},
+	}
+}
+
+func newCacheKey(clubId, gameId uint, leaderboardType LeaderboardType, minGames int, from, to *time.Time, since Since) cacheKey {
+	key := cacheKey{
+		clubId:          clubId,
+		gameId:          gameId,
+		leaderboardType: leaderboardType,
+		minGames:        minGames,
+		since:           since,
+	}
+
+	if from != nil {
+		key.from = *from
+	}
+
+	if to != nil {
+		key.to = *to
+	}
+
+	return key
+}
+
+func (c *cache) get(key cacheKey) (ranking, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.rankings[key]
+	if !ok || time.Now().After(r.expiresAt) {
+		cacheMetrics.Add("misses", 1)
+		return ranking{}, false
+	}
+
+	cacheMetrics.Add("hits", 1)
+
+	return r, true
+}
+
+// generation is to be taken before computing a ranking of the club, and handed to put along with it.
+func (c *cache) generation(clubId uint) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generations[clubId] + c.allGeneration
+}
+
+// put caches the ranking, unless the club was invalidated since the generation it was computed in.
+// The expired ones are dropped so that windows of time asked for once do not pile up.
+func (c *cache) put(key cacheKey, r ranking, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[key.clubId]+c.allGeneration != generation {
+		cacheMetrics.Add("stale", 1)
+		return
+	}
+
+	now := time.Now()
+	for k, cached := range c.rankings {
+		if now.After(cached.expiresAt) {
+			delete(c.rankings, k)
+			cacheMetrics.Add("size", -1)
+		}
+	}
+
+	if _, ok := c.rankings[key]; !ok {
+		cacheMetrics.Add("size", 1)
+	}
+
+	r.expiresAt = now.Add(cacheTTL)
+	c.rankings[key] = r
+}
+
+// invalidateClub drops the leaderboards of the club, or of every club if the club is 0.
+func (c *cache) invalidateClub(clubId uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if clubId == 0 {
+		c.allGeneration++
+	} else {
+		c.generations[clubId]++
+	}
+
+	for key := range c.rankings {
+		if clubId == 0 || key.clubId == clubId {
+			delete(c.rankings, key)
+			cacheMetrics.Add("invalidations", 1)
+			cacheMetrics.Add("size", -1)
+		}
+	}
+}
@@ -1,20 +1,94 @@
 package leaderboard
 
+import (
+	"matchlog/internal/statistic"
+	"time"
+)
+
 type LeaderboardType string
 
 const (
-	TypeWins   LeaderboardType = "wins"
-	TypeStreak LeaderboardType = "streak"
-	TypeRating LeaderboardType = "rating"
+	TypeWins              LeaderboardType = "wins"
+	TypeStreak            LeaderboardType = "streak"
+	TypeGoalsFor          LeaderboardType = "goals-for"
+	TypeGoalsAgainst      LeaderboardType = "goals-against"
+	TypeGoalDifference    LeaderboardType = "goal-difference"
+	TypeSetsWon           LeaderboardType = "sets-won"
+	TypeSetsLost          LeaderboardType = "sets-lost"
+	TypeWinRate           LeaderboardType = "winrate"
+	TypeLongestWinStreak  LeaderboardType = "longest-win-streak"
+	TypeLongestLossStreak LeaderboardType = "longest-loss-streak"
+	TypeRating            LeaderboardType = "rating"
+
+	TypeTeamRating LeaderboardType = "team-rating"
 )
 
+// better tells whether value a ranks above value b. Ratings rank highest first, as do most statistics.
+func (t LeaderboardType) better(a, b float64) bool {
+	return statistic.Measure(t).Better(a, b)
+}
+
 type Entry struct {
+	Rank   int     `json:"rank"`
 	Value  float64 `json:"value"`
-	UserId uint    `json:"user_id"`
+	UserId uint    `json:"user_id,omitempty"`
+	TeamId uint    `json:"team_id,omitempty"`
+	ClubId uint    `json:"club_id,omitempty"`
 	Name   string  `json:"name"`
+
+	// Players is how many players the value of a club is averaged over.
+	Players int `json:"players,omitempty"`
+
+	// RankChange is how many places the entry moved up since the reference point, or down if negative.
+	// It is left out for entries that were not on the leaderboard back then.
+	RankChange *int `json:"rank_change,omitempty"`
+	// Trend is the value at each of the latest snapshots, oldest first, followed by the current value.
+	Trend []float64 `json:"trend,omitempty"`
 }
 
+// id is the user, team or club the entry ranks.
+func (e Entry) id() uint {
+	if e.ClubId != 0 {
+		return e.ClubId
+	}
+
+	if e.TeamId != 0 {
+		return e.TeamId
+	}
+
+	return e.UserId
+}
+
+// Leaderboard is a page of the ranking, where Total is the number of users, or teams, ranked on every page.
 type Leaderboard struct {
-	Type    LeaderboardType `json:"type"`
-	Entries []Entry         `json:"entries"`
+	Type       LeaderboardType `json:"type"`
+	Total      int             `json:"total"`
+	Entries    []Entry         `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// Since is the reference point rank changes are measured from.
+type Since string
+
+const (
+	// SinceWeek compares with the leaderboard as it was a week ago.
+	SinceWeek Since = "week"
+	// SinceMatchDay compares with the leaderboard as it was before the day the last match was played.
+	SinceMatchDay Since = "match-day"
+)
+
+// Snapshot is the place of a user, or team, on a leaderboard of all time at the moment it was taken.
+// Every entry of a leaderboard is stored with the same TakenAt.
+type Snapshot struct {
+	Id uint `gorm:"primaryKey"`
+
+	ClubId  uint            `gorm:"not null;index:idx_snapshots_leaderboard"`
+	GameId  uint            `gorm:"not null;index:idx_snapshots_leaderboard"`
+	Type    LeaderboardType `gorm:"not null;size:32;index:idx_snapshots_leaderboard"`
+	TakenAt time.Time       `gorm:"not null;index:idx_snapshots_leaderboard"`
+
+	UserId uint
+	TeamId uint
+	Rank   int
+	Value  float64
 }
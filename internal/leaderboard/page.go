@@ -0,0 +1,131 @@
+package leaderboard
+
+import (
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrNotRanked     = errors.New("user is not on the leaderboard")
+)
+
+// Page selects part of a leaderboard. Entries either follow a cursor, are skipped up to an offset,
+// or surround a user with as many neighbours above and below.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor string
+
+	AroundUserId uint
+	Neighbours   int
+}
+
+// assignRanks orders entries best first, and by id between equal values, then numbers them.
+// Equal values share the rank of the first of them and the next rank skips as many places, as in 1, 2, 2, 4.
+func assignRanks(entries []Entry, leaderboardType LeaderboardType) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return leaderboardType.better(entries[i].Value, entries[j].Value)
+		}
+
+		return entries[i].id() < entries[j].id()
+	})
+
+	for i := range entries {
+		if i > 0 && entries[i].Value == entries[i-1].Value {
+			entries[i].Rank = entries[i-1].Rank
+			continue
+		}
+
+		entries[i].Rank = i + 1
+	}
+}
+
+// paginate returns the entries on the page and the cursor of the page after it, if there is one.
+// isUser tells whether an entry is, or for teams includes, the user a page is centered on.
+func paginate(entries []Entry, leaderboardType LeaderboardType, page Page, isUser func(Entry) bool) ([]Entry, string, error) {
+	if page.AroundUserId != 0 {
+		for i, entry := range entries {
+			if !isUser(entry) {
+				continue
+			}
+
+			start := i - page.Neighbours
+			if start < 0 {
+				start = 0
+			}
+
+			end := i + page.Neighbours + 1
+			if end > len(entries) {
+				end = len(entries)
+			}
+
+			return entries[start:end], "", nil
+		}
+
+		return nil, "", ErrNotRanked
+	}
+
+	start := page.Offset
+	if page.Cursor != "" {
+		var err error
+		start, err = cursorPosition(entries, leaderboardType, page.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if start > len(entries) {
+		start = len(entries)
+	}
+
+	end := start + page.Limit
+	if end >= len(entries) {
+		return entries[start:], "", nil
+	}
+
+	return entries[start:end], encodeCursor(entries[end-1]), nil
+}
+
+// A cursor points at the last entry of a page by its value and id, rather than by its position,
+// so a page does not repeat or skip entries when the leaderboard changes in between.
+func encodeCursor(entry Entry) string {
+	key := strconv.FormatFloat(entry.Value, 'g', -1, 64) + ":" + strconv.FormatUint(uint64(entry.id()), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// cursorPosition returns the position of the first entry ranked below the entry the cursor points at.
+func cursorPosition(entries []Entry, leaderboardType LeaderboardType, cursor string) (int, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	rawValue, rawId, ok := strings.Cut(string(key), ":")
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseUint(rawId, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	for i, entry := range entries {
+		if leaderboardType.better(value, entry.Value) || (entry.Value == value && uint64(entry.id()) > id) {
+			return i, nil
+		}
+	}
+
+	return len(entries), nil
+}
@@ -0,0 +1,65 @@
+package leaderboard
+
+import (
+	"context"
+	"matchlog/pkg/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	GetSnapshotTimes(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, before time.Time, limit int) ([]time.Time, error)
+	GetSnapshotsTakenAt(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, takenAt []time.Time) ([]Snapshot, error)
+	CreateSnapshots(ctx context.Context, snapshots []Snapshot) error
+}
+
+type RepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &RepositoryImpl{
+		db: db,
+	}
+}
+
+// GetSnapshotTimes returns when the latest snapshots of the leaderboard were taken, up to and including before, latest first.
+func (r *RepositoryImpl) GetSnapshotTimes(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, before time.Time, limit int) ([]time.Time, error) {
+	var times []time.Time
+	result := database.Conn(ctx, r.db).
+		Model(&Snapshot{}).
+		Distinct("taken_at").
+		Where("club_id = ? AND game_id = ? AND type = ? AND taken_at <= ?", clubId, gameId, leaderboardType, before).
+		Order("taken_at desc").
+		Limit(limit).
+		Pluck("taken_at", &times)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return times, nil
+}
+
+func (r *RepositoryImpl) GetSnapshotsTakenAt(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, takenAt []time.Time) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND type = ? AND taken_at IN ?", clubId, gameId, leaderboardType, takenAt).
+		Order("taken_at asc, `rank` asc").
+		Find(&snapshots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return snapshots, nil
+}
+
+func (r *RepositoryImpl) CreateSnapshots(ctx context.Context, snapshots []Snapshot) error {
+	result := database.Conn(ctx, r.db).
+		CreateInBatches(&snapshots, 500)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
@@ -3,101 +3,543 @@ package leaderboard
 import (
 	"context"
 	"matchlog/internal/club"
+	"matchlog/internal/match"
 	"matchlog/internal/rating"
 	"matchlog/internal/statistic"
+	"matchlog/internal/team"
 	"matchlog/internal/user"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+const (
+	// trendLength is the number of snapshots a trend goes back.
+	trendLength = 7
+)
+
+var (
+	ErrWindowNotSupported = errors.New("leaderboard type can not be limited to a window of time")
+	ErrGlobalNotSupported = errors.New("leaderboard type can not be compared across clubs")
+)
+
+// snapshotTypes are the leaderboards that are snapshotted, which is every one of them.
+var snapshotTypes = []LeaderboardType{
+	TypeWins, TypeStreak, TypeGoalsFor, TypeGoalsAgainst, TypeGoalDifference, TypeSetsWon, TypeSetsLost,
+	TypeWinRate, TypeLongestWinStreak, TypeLongestLossStreak, TypeRating, TypeTeamRating,
+}
+
 type Service interface {
-	GetLeaderboard(ctx context.Context, clubId uint, topX int, leaderboardType LeaderboardType) (*Leaderboard, error)
+	GetLeaderboard(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, minGames int, from, to *time.Time, since Since, page Page) (*Leaderboard, error)
+	GetGlobalLeaderboard(ctx context.Context, gameId uint, leaderboardType LeaderboardType, minGames int, page Page) (*Leaderboard, error)
+	GetClubsLeaderboard(ctx context.Context, gameId uint, leaderboardType LeaderboardType, minGames int, page Page) (*Leaderboard, error)
+	TakeSnapshots(ctx context.Context) error
 }
 
 type ServiceImpl struct {
+	repo             Repository
 	clubService      club.Service
 	userService      user.Service
 	ratingService    rating.Service
 	statisticService statistic.Service
+	teamService      team.Service
+	matchService     match.Service
+
+	cache *cache
 }
 
-func NewService(clubService club.Service, userService user.Service, ratingService rating.Service, statisticService statistic.Service) Service {
-	return &ServiceImpl{
+// NewService drops cached leaderboards of a club as soon as its standings or members change,
+// so nobody told about the change is served a leaderboard from before it.
+func NewService(repo Repository, clubService club.Service, userService user.Service, ratingService rating.Service, statisticService statistic.Service, teamService team.Service, matchService match.Service) Service {
+	s := &ServiceImpl{
+		repo:             repo,
 		clubService:      clubService,
 		userService:      userService,
 		ratingService:    ratingService,
 		statisticService: statisticService,
+		teamService:      teamService,
+		matchService:     matchService,
+		cache:            newCache(),
 	}
+
+	matchService.OnStandingsChanged(func(change match.StandingsChanged) {
+		s.cache.invalidateClub(change.ClubId)
+	})
+
+	clubService.OnMembersChanged(func(change club.MembersChanged) {
+		s.cache.invalidateClub(change.ClubId)
+	})
+
+	return s
 }
 
-func (s *ServiceImpl) GetLeaderboard(ctx context.Context, clubId uint, topX int, leaderboardType LeaderboardType) (*Leaderboard, error) {
-	var userIds []uint
-	var values []float64
+// GetLeaderboard ranks the users, or teams, of the club by the leaderboard type and returns a page of the ranking.
+// If from or to is given, statistics are computed from the matches played in that window instead of all time.
+// Ratings are built up over all matches, so rating leaderboards can not be limited to a window.
+// Anyone who played fewer than minGames matches, in the window if one is given, is left out.
+// Leaderboards of all time also show how each entry moved since the reference point, and its trend over the latest snapshots.
+func (s *ServiceImpl) GetLeaderboard(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, minGames int, from, to *time.Time, since Since, page Page) (*Leaderboard, error) {
+	windowed := from != nil || to != nil
+	if windowed && (leaderboardType == TypeRating || leaderboardType == TypeTeamRating) {
+		return nil, ErrWindowNotSupported
+	}
 
+	key := newCacheKey(clubId, gameId, leaderboardType, minGames, from, to, since)
+	r, ok := s.cache.get(key)
+	if !ok {
+		generation := s.cache.generation(clubId)
+
+		var err error
+		r, err = s.rank(ctx, clubId, gameId, leaderboardType, minGames, from, to, since)
+		if err != nil {
+			return nil, err
+		}
+
+		s.cache.put(key, r, generation)
+	}
+
+	// Around a user, a team leaderboard is centered on the best ranked team the user plays in.
+	paged, nextCursor, err := paginate(r.entries, leaderboardType, page, func(entry Entry) bool {
+		if entry.TeamId == 0 {
+			return entry.UserId == page.AroundUserId
+		}
+
+		for _, userId := range r.teams[entry.TeamId].UserIds {
+			if userId == page.AroundUserId {
+				return true
+			}
+		}
+
+		return false
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to paginate leaderboard")
+	}
+
+	lboard := &Leaderboard{
+		Type:       leaderboardType,
+		Total:      len(r.entries),
+		Entries:    append([]Entry{}, paged...),
+		NextCursor: nextCursor,
+	}
+
+	return lboard, nil
+}
+
+// rank computes every entry of the leaderboard, so that any page of it can be served from the cache.
+func (s *ServiceImpl) rank(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, minGames int, from, to *time.Time, since Since) (ranking, error) {
+	var entries []Entry
+	var teams map[uint]team.Team
+	var err error
+	if leaderboardType == TypeTeamRating {
+		entries, teams, err = s.rankTeams(ctx, clubId, gameId, minGames)
+	} else {
+		entries, err = s.rankUsers(ctx, clubId, gameId, leaderboardType, minGames, from, to)
+	}
+
+	if err != nil {
+		return ranking{}, err
+	}
+
+	assignRanks(entries, leaderboardType)
+
+	if from == nil && to == nil {
+		if err := s.addMovement(ctx, clubId, gameId, leaderboardType, since, entries, entries); err != nil {
+			return ranking{}, err
+		}
+	}
+
+	if err := s.addNames(ctx, entries, teams); err != nil {
+		return ranking{}, err
+	}
+
+	return ranking{entries: entries, teams: teams}, nil
+}
+
+// GetGlobalLeaderboard ranks the users that opted into it by their statistics in every club together.
+// Ratings are relative to the players of a club, and a current streak belongs to a single club, so neither is compared across clubs.
+// Anyone who played fewer than minGames matches in all clubs together is left out.
+func (s *ServiceImpl) GetGlobalLeaderboard(ctx context.Context, gameId uint, leaderboardType LeaderboardType, minGames int, page Page) (*Leaderboard, error) {
+	if leaderboardType == TypeRating || leaderboardType == TypeTeamRating || leaderboardType == TypeStreak {
+		return nil, ErrGlobalNotSupported
+	}
+
+	shownUserIds, err := s.userService.GetUserIdsShownOnGlobalLeaderboards(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get users shown on global leaderboards")
+	}
+
+	measure := statistic.Measure(leaderboardType)
+	userIds, values, err := s.statisticService.GetTopXAmongUserIdsByMeasureAcrossClubs(ctx, gameId, len(shownUserIds), minGames, shownUserIds, measure)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to rank userIds by %s across clubs", measure)
+	}
+
+	entries := make([]Entry, len(userIds))
+	for i, userId := range userIds {
+		entries[i] = Entry{
+			Value:  values[i],
+			UserId: userId,
+		}
+	}
+
+	assignRanks(entries, leaderboardType)
+
+	paged, nextCursor, err := paginate(entries, leaderboardType, page, func(entry Entry) bool {
+		return entry.UserId == page.AroundUserId
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to paginate leaderboard")
+	}
+
+	if err := s.addNames(ctx, paged, nil); err != nil {
+		return nil, err
+	}
+
+	lboard := &Leaderboard{
+		Type:       leaderboardType,
+		Total:      len(entries),
+		Entries:    paged,
+		NextCursor: nextCursor,
+	}
+
+	return lboard, nil
+}
+
+// GetClubsLeaderboard ranks clubs against each other by the average of the leaderboard type over their players,
+// so that a small office can keep up with a large one. Only players that opted into global leaderboards,
+// and played at least minGames matches in the club, count towards the average of a club.
+func (s *ServiceImpl) GetClubsLeaderboard(ctx context.Context, gameId uint, leaderboardType LeaderboardType, minGames int, page Page) (*Leaderboard, error) {
+	if leaderboardType == TypeRating || leaderboardType == TypeTeamRating {
+		return nil, ErrGlobalNotSupported
+	}
+
+	shownUserIds, err := s.userService.GetUserIdsShownOnGlobalLeaderboards(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get users shown on global leaderboards")
+	}
+
+	measure := statistic.Measure(leaderboardType)
+	clubIds, values, players, err := s.statisticService.GetAverageOfUserIdsByMeasurePerClub(ctx, gameId, minGames, shownUserIds, measure)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to rank clubs by %s", measure)
+	}
+
+	entries := make([]Entry, len(clubIds))
+	for i, clubId := range clubIds {
+		entries[i] = Entry{
+			Value:   values[i],
+			ClubId:  clubId,
+			Players: players[i],
+		}
+	}
+
+	assignRanks(entries, leaderboardType)
+
+	paged, nextCursor, err := paginate(entries, leaderboardType, page, func(Entry) bool {
+		return false
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to paginate leaderboard")
+	}
+
+	pagedClubIds := make([]uint, len(paged))
+	for i, entry := range paged {
+		pagedClubIds[i] = entry.ClubId
+	}
+
+	clubs, err := s.clubService.GetClubs(ctx, pagedClubIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get clubs")
+	}
+
+	// Clubs that are not discoverable are ranked without their names, which only their members should know.
+	names := make(map[uint]string, len(clubs))
+	for _, c := range clubs {
+		if c.Discoverable {
+			names[c.Id] = c.Name
+		}
+	}
+
+	for i := range paged {
+		paged[i].Name = names[paged[i].ClubId]
+	}
+
+	lboard := &Leaderboard{
+		Type:       leaderboardType,
+		Total:      len(entries),
+		Entries:    paged,
+		NextCursor: nextCursor,
+	}
+
+	return lboard, nil
+}
+
+// TakeSnapshots stores every leaderboard of all time of every game in every club as it is now.
+func (s *ServiceImpl) TakeSnapshots(ctx context.Context) error {
+	clubGames, err := s.clubService.GetAllClubsGames(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get games of all clubs")
+	}
+
+	takenAt := time.Now()
+
+	for _, clubGame := range clubGames {
+		var snapshots []Snapshot
+		for _, leaderboardType := range snapshotTypes {
+			var entries []Entry
+			if leaderboardType == TypeTeamRating {
+				entries, _, err = s.rankTeams(ctx, clubGame.ClubId, clubGame.GameId, 0)
+			} else {
+				entries, err = s.rankUsers(ctx, clubGame.ClubId, clubGame.GameId, leaderboardType, 0, nil, nil)
+			}
+
+			if err != nil {
+				return errors.Wrapf(err, "failed to rank %s of game %d in club %d", leaderboardType, clubGame.GameId, clubGame.ClubId)
+			}
+
+			assignRanks(entries, leaderboardType)
+
+			for _, entry := range entries {
+				snapshots = append(snapshots, Snapshot{
+					ClubId:  clubGame.ClubId,
+					GameId:  clubGame.GameId,
+					Type:    leaderboardType,
+					TakenAt: takenAt,
+					UserId:  entry.UserId,
+					TeamId:  entry.TeamId,
+					Rank:    entry.Rank,
+					Value:   entry.Value,
+				})
+			}
+		}
+
+		if len(snapshots) == 0 {
+			continue
+		}
+
+		if err := s.repo.CreateSnapshots(ctx, snapshots); err != nil {
+			return errors.Wrapf(err, "failed to store snapshots of game %d in club %d", clubGame.GameId, clubGame.ClubId)
+		}
+	}
+
+	// Rank changes and trends of every cached leaderboard are measured against the snapshots.
+	s.cache.invalidateClub(0)
+
+	return nil
+}
+
+// rankUsers orders every user of the club on the leaderboard, best first.
+func (s *ServiceImpl) rankUsers(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, minGames int, from, to *time.Time) ([]Entry, error) {
 	userIdsInClub, err := s.clubService.GetUserIdsInClub(ctx, clubId)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get users in Club %d", clubId)
 	}
 
+	// Everyone is ranked, so the whole ranking is known when giving out ranks and pages.
+	all := len(userIdsInClub)
+
+	var userIds []uint
+	var values []float64
+
 	switch leaderboardType {
-	case TypeWins:
-		ids, wins, err := s.statisticService.GetTopXAmongUserIdsByMeasure(ctx, topX, userIdsInClub, statistic.MeasureWins)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get top %d userIds by wins", topX)
+	case TypeWins, TypeStreak, TypeGoalsFor, TypeGoalsAgainst, TypeGoalDifference, TypeSetsWon, TypeSetsLost, TypeWinRate, TypeLongestWinStreak, TypeLongestLossStreak:
+		// Statistic leaderboards are named after the measure they rank by.
+		measure := statistic.Measure(leaderboardType)
+
+		if from != nil || to != nil {
+			windowStats, err := s.matchService.GetStatisticsBetween(ctx, clubId, gameId, from, to)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get statistics in window")
+			}
+
+			userIds, values, err = statistic.TopXAmongUserIdsByMeasure(windowStats, all, minGames, userIdsInClub, measure)
+		} else {
+			userIds, values, err = s.statisticService.GetTopXAmongUserIdsByMeasure(ctx, clubId, gameId, all, minGames, userIdsInClub, measure)
 		}
 
-		userIds = ids
-		values = s.convertIntToFloat64(wins)
-	case TypeStreak:
-		ids, winstreaks, err := s.statisticService.GetTopXAmongUserIdsByMeasure(ctx, topX, userIdsInClub, statistic.MeasureStreak)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get top %d userIds by streak", topX)
+			return nil, errors.Wrapf(err, "failed to rank userIds by %s", measure)
 		}
-
-		userIds = ids
-		values = s.convertIntToFloat64(winstreaks)
 	case TypeRating:
-		ids, ratings, err := s.ratingService.GetTopXAmongUserIdsByRating(ctx, topX, userIdsInClub)
+		userIds, values, err = s.ratingService.GetTopXAmongUserIdsByRating(ctx, clubId, gameId, all, minGames, userIdsInClub)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get top %d userIds by rating", topX)
+			return nil, errors.Wrap(err, "failed to rank userIds by rating")
 		}
-
-		userIds = ids
-		values = s.convertIntToFloat64(ratings)
 	default:
 		return nil, errors.Errorf("unknown leaderboard type: %s", leaderboardType)
 	}
 
-	users, err := s.userService.GetUsers(ctx, userIds)
+	entries := make([]Entry, len(userIds))
+	for i, userId := range userIds {
+		entries[i] = Entry{
+			Value:  values[i],
+			UserId: userId,
+		}
+	}
+
+	return entries, nil
+}
+
+// rankTeams orders every team of the club by rating, best first, and returns the teams by id.
+func (s *ServiceImpl) rankTeams(ctx context.Context, clubId, gameId uint, minGames int) ([]Entry, map[uint]team.Team, error) {
+	teamsInClub, err := s.teamService.GetTeamsInClub(ctx, clubId)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get teams in Club %d", clubId)
+	}
+
+	teamIdsInClub := make([]uint, len(teamsInClub))
+	teams := make(map[uint]team.Team, len(teamsInClub))
+	for i, t := range teamsInClub {
+		teamIdsInClub[i] = t.Id
+		teams[t.Id] = t
+	}
+
+	teamIds, ratings, err := s.ratingService.GetTopXAmongTeamIdsByRating(ctx, clubId, gameId, len(teamIdsInClub), minGames, teamIdsInClub)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get users")
+		return nil, nil, errors.Wrap(err, "failed to rank teamIds by rating")
 	}
 
-	entries := make([]Entry, len(users))
-	for i, u := range users {
+	entries := make([]Entry, len(teamIds))
+	for i, teamId := range teamIds {
 		entries[i] = Entry{
-			Value:  values[i],
-			UserId: u.Id,
-			Name:   u.Name,
+			Value:  ratings[i],
+			TeamId: teamId,
+		}
+	}
+
+	return entries, teams, nil
+}
+
+// addMovement sets the rank change and trend of the paged entries from the snapshots of the leaderboard.
+// The snapshot at the reference point is ranked again among the entries on the leaderboard now,
+// so that users left out by minGames do not count as places moved.
+func (s *ServiceImpl) addMovement(ctx context.Context, clubId, gameId uint, leaderboardType LeaderboardType, since Since, entries, paged []Entry) error {
+	now := time.Now()
+
+	var reference *time.Time
+	switch since {
+	case SinceWeek:
+		weekAgo := now.AddDate(0, 0, -7)
+		reference = &weekAgo
+	case SinceMatchDay:
+		lastPlayedAt, err := s.matchService.GetLastPlayedAt(ctx, clubId, gameId)
+		if err != nil {
+			return errors.Wrap(err, "failed to get when the last match was played")
+		}
+
+		if lastPlayedAt != nil {
+			year, month, day := lastPlayedAt.Date()
+			matchDay := time.Date(year, month, day, 0, 0, 0, 0, lastPlayedAt.Location())
+			reference = &matchDay
 		}
+	default:
+		return errors.Errorf("unknown reference point: %s", since)
 	}
 
+	takenAt, err := s.repo.GetSnapshotTimes(ctx, clubId, gameId, leaderboardType, now, trendLength)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get leaderboard")
+		return errors.Wrap(err, "failed to get snapshot times")
 	}
 
-	lboard := &Leaderboard{
-		Type:    leaderboardType,
-		Entries: entries,
+	var referenceTakenAt []time.Time
+	if reference != nil {
+		referenceTakenAt, err = s.repo.GetSnapshotTimes(ctx, clubId, gameId, leaderboardType, *reference, 1)
+		if err != nil {
+			return errors.Wrap(err, "failed to get snapshot time at reference point")
+		}
 	}
 
-	return lboard, nil
+	if len(takenAt)+len(referenceTakenAt) == 0 {
+		return nil
+	}
+
+	snapshots, err := s.repo.GetSnapshotsTakenAt(ctx, clubId, gameId, leaderboardType, append(takenAt, referenceTakenAt...))
+	if err != nil {
+		return errors.Wrap(err, "failed to get snapshots")
+	}
+
+	onLeaderboard := make(map[uint]bool, len(entries))
+	for _, entry := range entries {
+		onLeaderboard[entry.id()] = true
+	}
+
+	inTrend := make(map[int64]bool, len(takenAt))
+	for _, t := range takenAt {
+		inTrend[t.UnixNano()] = true
+	}
+
+	var before []Entry
+	trends := map[uint][]float64{}
+	for _, snapshot := range snapshots {
+		entry := Entry{Value: snapshot.Value, UserId: snapshot.UserId, TeamId: snapshot.TeamId}
+
+		// Snapshots come oldest first, so trends are built up in order.
+		if inTrend[snapshot.TakenAt.UnixNano()] {
+			trends[entry.id()] = append(trends[entry.id()], entry.Value)
+		}
+
+		if len(referenceTakenAt) > 0 && snapshot.TakenAt.Equal(referenceTakenAt[0]) && onLeaderboard[entry.id()] {
+			before = append(before, entry)
+		}
+	}
+
+	assignRanks(before, leaderboardType)
+
+	rankBefore := make(map[uint]int, len(before))
+	for _, entry := range before {
+		rankBefore[entry.id()] = entry.Rank
+	}
+
+	for i := range paged {
+		if rank, ok := rankBefore[paged[i].id()]; ok {
+			change := rank - paged[i].Rank
+			paged[i].RankChange = &change
+		}
+
+		if trend, ok := trends[paged[i].id()]; ok {
+			paged[i].Trend = append(trend, paged[i].Value)
+		}
+	}
+
+	return nil
 }
 
-func (s *ServiceImpl) convertIntToFloat64(values []int) []float64 {
-	floats := make([]float64, len(values))
-	for i, v := range values {
-		floats[i] = float64(v)
+// addNames names the entries after their user, or the members of their team.
+func (s *ServiceImpl) addNames(ctx context.Context, entries []Entry, teams map[uint]team.Team) error {
+	userIds := []uint{}
+	for _, entry := range entries {
+		if entry.TeamId == 0 {
+			userIds = append(userIds, entry.UserId)
+		} else {
+			userIds = append(userIds, teams[entry.TeamId].UserIds...)
+		}
+	}
+
+	users, err := s.userService.GetUsers(ctx, userIds)
+	if err != nil {
+		return errors.Wrap(err, "failed to get users")
+	}
+
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.Id] = u.Name
+	}
+
+	for i, entry := range entries {
+		if entry.TeamId == 0 {
+			entries[i].Name = names[entry.UserId]
+			continue
+		}
+
+		memberNames := make([]string, len(teams[entry.TeamId].UserIds))
+		for j, userId := range teams[entry.TeamId].UserIds {
+			memberNames[j] = names[userId]
+		}
+
+		entries[i].Name = strings.Join(memberNames, " & ")
 	}
-	return floats
+
+	return nil
 }
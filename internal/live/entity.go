@@ -0,0 +1,84 @@
+package live
+
+import (
+	"time"
+)
+
+type Status string
+
+const (
+	StatusLive      Status = "live"
+	StatusFinished  Status = "finished"
+	StatusCancelled Status = "cancelled"
+)
+
+type Side string
+
+const (
+	SideA Side = "A"
+	SideB Side = "B"
+)
+
+type EventType string
+
+const (
+	// EventPoint adds points to a side in the current set. Negative points correct a miscount.
+	EventPoint EventType = "point"
+	// EventSet ends the current set and starts the next one.
+	EventSet EventType = "set"
+)
+
+type Event struct {
+	Type   EventType
+	Side   Side
+	Points int
+}
+
+// Match is the state of a match while it is being played.
+// It only lives in memory, and becomes a match.Match once it is finished.
+type Match struct {
+	Id uint `json:"id"`
+
+	ClubId    uint `json:"clubId"`
+	GameId    uint `json:"gameId"`
+	StartedBy uint `json:"startedBy"`
+
+	TeamA []uint `json:"teamA"`
+	TeamB []uint `json:"teamB"`
+	Rated bool   `json:"rated"`
+
+	// ScoresA and ScoresB hold the score of every set so far, the last one being the current set.
+	ScoresA []int `json:"scoresA"`
+	ScoresB []int `json:"scoresB"`
+
+	Status Status `json:"status"`
+
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CanScore reports whether the user may push events for the match, which is anyone playing in it and whoever started it.
+func (m *Match) CanScore(userId uint) bool {
+	if m.StartedBy == userId {
+		return true
+	}
+
+	for _, id := range append(append([]uint{}, m.TeamA...), m.TeamB...) {
+		if id == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// snapshot copies the match, so it can be handed to subscribers while scoring goes on.
+func (m *Match) snapshot() Match {
+	s := *m
+	s.TeamA = append([]uint{}, m.TeamA...)
+	s.TeamB = append([]uint{}, m.TeamB...)
+	s.ScoresA = append([]int{}, m.ScoresA...)
+	s.ScoresB = append([]int{}, m.ScoresB...)
+
+	return s
+}
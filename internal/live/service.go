@@ -0,0 +1,376 @@
+package live
+
+import (
+	"context"
+	"matchlog/internal/club"
+	"matchlog/internal/match"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotFound     = errors.New("live match not found")
+	ErrNotAllowed   = errors.New("user is not allowed to score live match")
+	ErrInvalidEvent = errors.New("invalid event")
+	ErrFinishing    = errors.New("live match is being finished")
+)
+
+type Service interface {
+	GetMatch(ctx context.Context, id uint) (*Match, error)
+	GetMatchesInClub(ctx context.Context, clubId uint) ([]Match, error)
+	StartMatch(ctx context.Context, userId, clubId, gameId uint, teamA, teamB []uint, rated bool) (*Match, error)
+	PushEvent(ctx context.Context, id uint, userId uint, event Event) (*Match, error)
+	FinishMatch(ctx context.Context, id uint, userId uint) error
+	CancelMatch(ctx context.Context, id uint, userId uint) error
+	Subscribe(ctx context.Context, id uint) (updates <-chan Match, unsubscribe func(), err error)
+	CancelIdleMatches(ctx context.Context) int
+}
+
+// session is a match being played, along with everyone watching it.
+type session struct {
+	mu          sync.Mutex
+	match       Match
+	subscribers map[chan Match]struct{}
+	// finishing is set while the match is being recorded, which happens without holding the lock.
+	finishing bool
+}
+
+// ServiceImpl keeps the matches being played in memory, so they are lost on restart.
+type ServiceImpl struct {
+	clubService  club.Service
+	matchService match.Service
+
+	// idleTimeout is how long a match can go without being scored before it is cancelled.
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	lastId   uint
+	sessions map[uint]*session
+}
+
+func NewService(clubService club.Service, matchService match.Service, idleTimeout time.Duration) Service {
+	return &ServiceImpl{
+		clubService:  clubService,
+		matchService: matchService,
+		idleTimeout:  idleTimeout,
+		sessions:     map[uint]*session{},
+	}
+}
+
+func (s *ServiceImpl) GetMatch(ctx context.Context, id uint) (*Match, error) {
+	sess, err := s.getSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	m := sess.match.snapshot()
+
+	return &m, nil
+}
+
+func (s *ServiceImpl) GetMatchesInClub(ctx context.Context, clubId uint) ([]Match, error) {
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	matches := []Match{}
+	for _, sess := range sessions {
+		sess.mu.Lock()
+		if sess.match.ClubId == clubId {
+			matches = append(matches, sess.match.snapshot())
+		}
+		sess.mu.Unlock()
+	}
+
+	return matches, nil
+}
+
+// StartMatch starts scoring a match with a first set at 0-0.
+// The players have to be members of the club, the game and score are validated once the match is finished and recorded.
+func (s *ServiceImpl) StartMatch(ctx context.Context, userId, clubId, gameId uint, teamA, teamB []uint, rated bool) (*Match, error) {
+	if _, err := s.clubService.GetMembership(ctx, userId, clubId); err != nil {
+		if errors.Is(err, club.ErrNotFound) {
+			return nil, ErrNotAllowed
+		}
+
+		return nil, errors.Wrapf(err, "failed to get membership of user %d", userId)
+	}
+
+	for _, playerId := range append(append([]uint{}, teamA...), teamB...) {
+		_, err := s.clubService.GetMembership(ctx, playerId, clubId)
+		if errors.Is(err, club.ErrNotFound) {
+			return nil, errors.Wrapf(match.ErrNotClubMember, "user %d", playerId)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get membership of user %d", playerId)
+		}
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastId++
+
+	sess := &session{
+		match: Match{
+			Id:        s.lastId,
+			ClubId:    clubId,
+			GameId:    gameId,
+			StartedBy: userId,
+			TeamA:     teamA,
+			TeamB:     teamB,
+			Rated:     rated,
+			ScoresA:   []int{0},
+			ScoresB:   []int{0},
+			Status:    StatusLive,
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+		subscribers: map[chan Match]struct{}{},
+	}
+
+	s.sessions[sess.match.Id] = sess
+
+	m := sess.match.snapshot()
+
+	return &m, nil
+}
+
+func (s *ServiceImpl) PushEvent(ctx context.Context, id uint, userId uint, event Event) (*Match, error) {
+	sess, err := s.getSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if !sess.match.CanScore(userId) {
+		return nil, ErrNotAllowed
+	}
+
+	if sess.finishing {
+		return nil, ErrFinishing
+	}
+
+	current := len(sess.match.ScoresA) - 1
+
+	switch event.Type {
+	case EventPoint:
+		var score *int
+		switch event.Side {
+		case SideA:
+			score = &sess.match.ScoresA[current]
+		case SideB:
+			score = &sess.match.ScoresB[current]
+		default:
+			return nil, errors.Wrapf(ErrInvalidEvent, "unknown side %q", event.Side)
+		}
+
+		if *score+event.Points < 0 {
+			return nil, errors.Wrap(ErrInvalidEvent, "score can not go below zero")
+		}
+
+		*score += event.Points
+	case EventSet:
+		sess.match.ScoresA = append(sess.match.ScoresA, 0)
+		sess.match.ScoresB = append(sess.match.ScoresB, 0)
+	default:
+		return nil, errors.Wrapf(ErrInvalidEvent, "unknown event type %q", event.Type)
+	}
+
+	sess.match.UpdatedAt = time.Now()
+	sess.broadcast()
+
+	m := sess.match.snapshot()
+
+	return &m, nil
+}
+
+// FinishMatch records the final score as a match, which is then confirmed like any other submitted match.
+// If the score is rejected, for instance because it is not a possible result of the game, the match stays live so it can be corrected.
+// The session is not locked while the match is recorded, so it can still be watched, but not scored, in the meantime.
+func (s *ServiceImpl) FinishMatch(ctx context.Context, id uint, userId uint) error {
+	sess, err := s.getSession(id)
+	if err != nil {
+		return err
+	}
+
+	sess.mu.Lock()
+	if !sess.match.CanScore(userId) {
+		sess.mu.Unlock()
+		return ErrNotAllowed
+	}
+
+	if sess.finishing {
+		sess.mu.Unlock()
+		return ErrFinishing
+	}
+
+	sess.finishing = true
+	final := sess.match.snapshot()
+	sess.mu.Unlock()
+
+	submission := match.Submission{
+		SubmittedBy: userId,
+		ClubId:      final.ClubId,
+		GameId:      final.GameId,
+		TeamA:       final.TeamA,
+		TeamB:       final.TeamB,
+		ScoresA:     final.ScoresA,
+		ScoresB:     final.ScoresB,
+		Rated:       final.Rated,
+	}
+
+	_, err = s.matchService.CreateMatch(ctx, submission)
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.finishing = false
+	if err != nil {
+		return errors.Wrapf(err, "failed to record live match %d", id)
+	}
+
+	s.end(sess, StatusFinished)
+
+	return nil
+}
+
+func (s *ServiceImpl) CancelMatch(ctx context.Context, id uint, userId uint) error {
+	sess, err := s.getSession(id)
+	if err != nil {
+		return err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if !sess.match.CanScore(userId) {
+		return ErrNotAllowed
+	}
+
+	if sess.finishing {
+		return ErrFinishing
+	}
+
+	s.end(sess, StatusCancelled)
+
+	return nil
+}
+
+// CancelIdleMatches cancels the matches that have not been scored for longer than the idle timeout,
+// so matches nobody finished do not pile up. It returns how many were cancelled.
+func (s *ServiceImpl) CancelIdleMatches(ctx context.Context) int {
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	idleSince := time.Now().Add(-s.idleTimeout)
+
+	cancelled := 0
+	for _, sess := range sessions {
+		sess.mu.Lock()
+		// A match that already ended was removed while the sessions were collected.
+		if sess.match.Status == StatusLive && !sess.finishing && sess.match.UpdatedAt.Before(idleSince) {
+			s.end(sess, StatusCancelled)
+			cancelled++
+		}
+		sess.mu.Unlock()
+	}
+
+	return cancelled
+}
+
+// Subscribe returns a channel receiving the state of the match, starting with the current one, until it ends.
+// Slow subscribers only get the latest state.
+func (s *ServiceImpl) Subscribe(ctx context.Context, id uint) (<-chan Match, func(), error) {
+	sess, err := s.getSession(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	updates := make(chan Match, 1)
+	updates <- sess.match.snapshot()
+
+	// The match may have ended while the session was looked up.
+	if sess.match.Status != StatusLive {
+		close(updates)
+		return updates, func() {}, nil
+	}
+
+	sess.subscribers[updates] = struct{}{}
+
+	unsubscribe := func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+
+		if _, ok := sess.subscribers[updates]; ok {
+			delete(sess.subscribers, updates)
+			close(updates)
+		}
+	}
+
+	return updates, unsubscribe, nil
+}
+
+func (s *ServiceImpl) getSession(id uint) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return sess, nil
+}
+
+// end removes the match and sends its final state to the subscribers before letting them go.
+// The session lock must be held.
+func (s *ServiceImpl) end(sess *session, status Status) {
+	s.mu.Lock()
+	delete(s.sessions, sess.match.Id)
+	s.mu.Unlock()
+
+	sess.match.Status = status
+	sess.match.UpdatedAt = time.Now()
+	sess.broadcast()
+
+	for updates := range sess.subscribers {
+		delete(sess.subscribers, updates)
+		close(updates)
+	}
+}
+
+// broadcast sends the current state to every subscriber, replacing a state they have not received yet.
+// The session lock must be held.
+func (sess *session) broadcast() {
+	state := sess.match.snapshot()
+
+	for updates := range sess.subscribers {
+		select {
+		case <-updates:
+		default:
+		}
+
+		updates <- state
+	}
+}
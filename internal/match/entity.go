@@ -1,7 +1,17 @@
 package match
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"matchlog/internal/game"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 type Result rune
@@ -10,15 +20,254 @@ const (
 	TeamAWins Result = 'A'
 	TeamBWins Result = 'B'
 	Draw      Result = 'D'
+	// Placed is the result of a free-for-all match, which has placements instead of a winning side.
+	Placed Result = 'P'
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusConfirmed Status = "confirmed"
+	StatusDisputed  Status = "disputed"
 )
 
 type Match struct {
 	Id uint `gorm:"primaryKey"`
 
-	TeamA  []uint   `gorm:"serializer:json;not null"`
-	TeamB  []uint   `gorm:"serializer:json;not null"`
-	Sets   []string `gorm:"serializer:json;not null"`
-	Result Result   `gorm:"not null"`
+	ClubId      uint `gorm:"index"`
+	GameId      uint `gorm:"index"`
+	SubmittedBy uint `gorm:"index;uniqueIndex:idx_matches_submitted_by_idempotency_key"`
+
+	// IdempotencyKey is chosen by the submitting client, so a retried submission is only recorded once.
+	IdempotencyKey *string `gorm:"size:255;uniqueIndex:idx_matches_submitted_by_idempotency_key"`
+	// SubmissionHash fingerprints the submission made with the idempotency key, so the key can not be reused for another match.
+	SubmissionHash string `gorm:"size:64"`
+
+	// Type is the type of the game at the time the match was recorded.
+	// Free-for-all and cooperative matches keep all of their players in team A,
+	// a cooperative match is won by team A or lost to the game as team B.
+	Type game.GameType `gorm:"not null;default:team"`
+
+	TeamA   []uint   `gorm:"serializer:json;not null"`
+	TeamB   []uint   `gorm:"serializer:json;not null"`
+	TeamAId uint     `gorm:"index"`
+	TeamBId uint     `gorm:"index"`
+	Sets    []string `gorm:"serializer:json;not null"`
+	Result  Result   `gorm:"not null"`
+	Rated   bool
+
+	// Placements are the finishing places of the players of a free-for-all match, aligned with team A.
+	// 1 is the winner, and players sharing a place tied.
+	Placements []int `gorm:"serializer:json"`
+	// Scores are the final scores of the players of a free-for-all match, aligned with team A, if it was scored.
+	Scores []int `gorm:"serializer:json"`
+
+	Status      Status `gorm:"not null;index"`
+	ConfirmedBy uint
+	ConfirmedAt *time.Time `gorm:"index"`
+	DisputedBy  uint
+	// ReopenedAt is when a correction last sent the match back to pending.
+	// The confirmation timeout counts from it, or from CreatedAt if the match was never reopened.
+	ReopenedAt *time.Time
 
 	CreatedAt time.Time
 }
+
+// Sides returns the winning and losing players and teams of the match.
+// On a draw team A is returned as the winning side.
+func (m *Match) Sides() (winners, losers []uint, winningTeamId, losingTeamId uint) {
+	if m.Result == TeamBWins {
+		return m.TeamB, m.TeamA, m.TeamBId, m.TeamAId
+	}
+
+	return m.TeamA, m.TeamB, m.TeamAId, m.TeamBId
+}
+
+// PlacementsByUserId returns the placement of every player of a free-for-all match, or nil for any other match.
+func (m *Match) PlacementsByUserId() map[uint]int {
+	if m.Result != Placed {
+		return nil
+	}
+
+	placements := make(map[uint]int, len(m.TeamA))
+	for i, userId := range m.TeamA {
+		placements[userId] = m.Placements[i]
+	}
+
+	return placements
+}
+
+// CanConfirm reports whether the user may confirm the match, which is anyone on the side opposing the submitter.
+// If the submitter did not play, or there is no opposing side, any other player may confirm.
+func (m *Match) CanConfirm(userId uint) bool {
+	switch {
+	case contains(m.TeamA, m.SubmittedBy) && len(m.TeamB) > 0:
+		return contains(m.TeamB, userId)
+	case contains(m.TeamB, m.SubmittedBy):
+		return contains(m.TeamA, userId)
+	default:
+		return userId != m.SubmittedBy && m.IsPlayer(userId)
+	}
+}
+
+func (m *Match) IsPlayer(userId uint) bool {
+	return contains(m.TeamA, userId) || contains(m.TeamB, userId)
+}
+
+// scores parses the sets of the match back into the scores of each side.
+func (m *Match) scores() (scoresA, scoresB []int, err error) {
+	scoresA = make([]int, len(m.Sets))
+	scoresB = make([]int, len(m.Sets))
+	for i, set := range m.Sets {
+		if _, err := fmt.Sscanf(set, "%d-%d", &scoresA[i], &scoresB[i]); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse set %q of match %d", set, m.Id)
+		}
+	}
+
+	return scoresA, scoresB, nil
+}
+
+// players returns the players of both sides.
+func (m *Match) players() []uint {
+	return append(append([]uint{}, m.TeamA...), m.TeamB...)
+}
+
+// teamIds returns the teams of the sides that are teams.
+func (m *Match) teamIds() []uint {
+	var teamIds []uint
+	for _, teamId := range []uint{m.TeamAId, m.TeamBId} {
+		if teamId != 0 {
+			teamIds = append(teamIds, teamId)
+		}
+	}
+
+	return teamIds
+}
+
+func contains(ids []uint, id uint) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Submission is a match result as reported by a user.
+// Which fields make up the result depends on the type of the game, see ServiceImpl.CreateMatch.
+type Submission struct {
+	SubmittedBy uint
+	ClubId      uint
+	GameId      uint
+	TeamA       []uint
+	TeamB       []uint
+	ScoresA     []int
+	ScoresB     []int
+	Rated       bool
+
+	// IdempotencyKey identifies the submission among those of the submitter, if the client retries submissions.
+	IdempotencyKey string
+
+	// Players are the players of a free-for-all or cooperative match.
+	Players []uint
+	// Placements or Scores are the result of a free-for-all match, aligned with Players.
+	Placements []int
+	Scores     []int
+	// Won is the result of a cooperative match.
+	Won *bool
+
+	// Events is the optional log of what happened during the match, in order.
+	Events []Event
+
+	// PlayedAt is when a historical match was played, it is only used when importing.
+	PlayedAt time.Time
+}
+
+// players returns everyone taking part in the submitted match.
+func (s Submission) players() []uint {
+	return append(append(append([]uint{}, s.TeamA...), s.TeamB...), s.Players...)
+}
+
+// hash fingerprints everything the submission records, leaving out the idempotency key it is looked up by.
+func (s Submission) hash() (string, error) {
+	s.IdempotencyKey = ""
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ListFilter narrows down a listing of matches.
+// Opponent and partner are relative to the player, so they are ignored without one.
+type ListFilter struct {
+	ClubId     uint
+	GameId     uint
+	PlayerId   uint
+	OpponentId uint
+	PartnerId  uint
+	From       *time.Time
+	To         *time.Time
+	Rated      *bool
+	Status     Status
+
+	// Cursor is the nextCursor returned with the previous page, matches are listed newest first.
+	Cursor string
+	Limit  int
+
+	// after is the last match on the previous page, which the cursor is decoded into.
+	after *listPosition
+}
+
+// listPosition is where a match is in a listing, by when it was played and by id between matches played at the same time.
+type listPosition struct {
+	createdAt time.Time
+	id        uint
+}
+
+// A cursor points at the last match of a page by when it was played rather than by id alone,
+// as imported matches can be played before matches with lower ids.
+func encodeListCursor(m Match) string {
+	key := strconv.FormatInt(m.CreatedAt.UnixNano(), 10) + ":" + strconv.FormatUint(uint64(m.Id), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeListCursor(cursor string) (*listPosition, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	rawCreatedAt, rawId, ok := strings.Cut(string(key), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, err := strconv.ParseInt(rawCreatedAt, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseUint(rawId, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &listPosition{
+		createdAt: time.Unix(0, createdAt),
+		id:        uint(id),
+	}, nil
+}
+
+// StandingsChanged is published once a change to the statistics and ratings of a game in a club is committed,
+// such as when a match there is confirmed, corrected or deleted.
+type StandingsChanged struct {
+	ClubId uint
+	GameId uint
+}
@@ -0,0 +1,101 @@
+package match
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidEvents = errors.New("invalid event log")
+
+type EventType string
+
+const (
+	EventGoal    EventType = "goal"
+	EventTimeout EventType = "timeout"
+	EventSwap    EventType = "swap"
+)
+
+type Side string
+
+const (
+	SideA Side = "A"
+	SideB Side = "B"
+)
+
+// Event is an entry in the log of what happened during a match, such as a goal, a timeout or players swapping positions.
+type Event struct {
+	Id uint `gorm:"primaryKey"`
+
+	MatchId  uint `gorm:"not null;index:idx_match_events_match_sequence,unique"`
+	Sequence int  `gorm:"not null;index:idx_match_events_match_sequence,unique"`
+
+	Type EventType `gorm:"not null"`
+	Set  int       `gorm:"not null"`
+	// Side is the side the event counts for, which for an own goal is not the side of the scorer.
+	Side     Side `gorm:"not null"`
+	UserId   uint
+	Position string
+
+	OccurredAt time.Time `gorm:"not null"`
+}
+
+func (Event) TableName() string {
+	return "match_events"
+}
+
+// validateEvents checks that the event log agrees with the players and sets of the match.
+// A log with goals in it has to account for every goal of the match.
+func validateEvents(match *Match, events []Event) error {
+	scoresA, scoresB, err := match.scores()
+	if err != nil {
+		return err
+	}
+
+	goalsA := make([]int, len(scoresA))
+	goalsB := make([]int, len(scoresB))
+	hasGoals := false
+
+	for i, event := range events {
+		if event.Set < 1 || event.Set > len(scoresA) {
+			return errors.Wrapf(ErrInvalidEvents, "event %d is in set %d of %d", i+1, event.Set, len(scoresA))
+		}
+
+		if event.UserId != 0 && !match.IsPlayer(event.UserId) {
+			return errors.Wrapf(ErrInvalidEvents, "event %d is by user %d who did not play", i+1, event.UserId)
+		}
+
+		if i > 0 && event.OccurredAt.Before(events[i-1].OccurredAt) {
+			return errors.Wrapf(ErrInvalidEvents, "event %d happened before the event preceding it", i+1)
+		}
+
+		switch event.Type {
+		case EventGoal:
+			hasGoals = true
+
+			switch event.Side {
+			case SideA:
+				goalsA[event.Set-1]++
+			case SideB:
+				goalsB[event.Set-1]++
+			default:
+				return errors.Wrapf(ErrInvalidEvents, "goal %d is for unknown side %q", i+1, event.Side)
+			}
+		case EventTimeout, EventSwap:
+		default:
+			return errors.Wrapf(ErrInvalidEvents, "event %d has unknown type %q", i+1, event.Type)
+		}
+	}
+
+	if !hasGoals {
+		return nil
+	}
+
+	for i := range scoresA {
+		if goalsA[i] != scoresA[i] || goalsB[i] != scoresB[i] {
+			return errors.Wrapf(ErrInvalidEvents, "goals in set %d add up to %d-%d, not %d-%d", i+1, goalsA[i], goalsB[i], scoresA[i], scoresB[i])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package match
+
+import (
+	"sort"
+)
+
+// HeadToHead is the record of a player against an opponent, over the matches where they played against each other.
+// Sets and goals only count in team matches, free-for-all matches are won by whoever of the two placed better.
+type HeadToHead struct {
+	UserId         uint `json:"userId"`
+	OpponentId     uint `json:"opponentId"`
+	Matches        int  `json:"matches"`
+	Wins           int  `json:"wins"`
+	Draws          int  `json:"draws"`
+	Losses         int  `json:"losses"`
+	SetsWon        int  `json:"setsWon"`
+	SetsLost       int  `json:"setsLost"`
+	SetDifference  int  `json:"setDifference"`
+	GoalsFor       int  `json:"goalsFor"`
+	GoalsAgainst   int  `json:"goalsAgainst"`
+	GoalDifference int  `json:"goalDifference"`
+}
+
+// Partnership is the record of a player together with a teammate, over the matches where they played on the same side.
+type Partnership struct {
+	PartnerId uint    `json:"partnerId"`
+	Matches   int     `json:"matches"`
+	Wins      int     `json:"wins"`
+	Draws     int     `json:"draws"`
+	Losses    int     `json:"losses"`
+	WinRate   float64 `json:"winRate"`
+}
+
+// HeadToHeadRecord derives the record of a player against an opponent from the matches they played.
+// Matches where they were not opponents are skipped.
+func HeadToHeadRecord(userId, opponentId uint, matches []Match) (HeadToHead, error) {
+	record := HeadToHead{
+		UserId:     userId,
+		OpponentId: opponentId,
+	}
+
+	for i := range matches {
+		m := &matches[i]
+
+		if m.Result == Placed {
+			placements := m.PlacementsByUserId()
+			placement, ok := placements[userId]
+			opponentPlacement, opponentOk := placements[opponentId]
+			if !ok || !opponentOk {
+				continue
+			}
+
+			record.Matches++
+			switch {
+			case placement < opponentPlacement:
+				record.Wins++
+			case placement > opponentPlacement:
+				record.Losses++
+			default:
+				record.Draws++
+			}
+
+			continue
+		}
+
+		var onSideA bool
+		switch {
+		case contains(m.TeamA, userId) && contains(m.TeamB, opponentId):
+			onSideA = true
+		case contains(m.TeamB, userId) && contains(m.TeamA, opponentId):
+			onSideA = false
+		default:
+			continue
+		}
+
+		scoresA, scoresB, err := m.scores()
+		if err != nil {
+			return HeadToHead{}, err
+		}
+
+		scoresFor, scoresAgainst := scoresA, scoresB
+		if !onSideA {
+			scoresFor, scoresAgainst = scoresB, scoresA
+		}
+
+		for set := range scoresFor {
+			record.GoalsFor += scoresFor[set]
+			record.GoalsAgainst += scoresAgainst[set]
+
+			if scoresFor[set] > scoresAgainst[set] {
+				record.SetsWon++
+			} else if scoresAgainst[set] > scoresFor[set] {
+				record.SetsLost++
+			}
+		}
+
+		record.Matches++
+		switch {
+		case m.Result == Draw:
+			record.Draws++
+		case (m.Result == TeamAWins) == onSideA:
+			record.Wins++
+		default:
+			record.Losses++
+		}
+	}
+
+	record.SetDifference = record.SetsWon - record.SetsLost
+	record.GoalDifference = record.GoalsFor - record.GoalsAgainst
+
+	return record, nil
+}
+
+// PartnerRecords derives the records of a player with each of their teammates from the matches they played,
+// most frequent partner first. Free-for-all matches have no teammates and are skipped.
+func PartnerRecords(userId uint, matches []Match) []Partnership {
+	partnerships := map[uint]*Partnership{}
+
+	for _, m := range matches {
+		if m.Result == Placed {
+			continue
+		}
+
+		team, won := m.TeamA, m.Result == TeamAWins
+		if contains(m.TeamB, userId) {
+			team, won = m.TeamB, m.Result == TeamBWins
+		} else if !contains(m.TeamA, userId) {
+			continue
+		}
+
+		for _, partnerId := range team {
+			if partnerId == userId {
+				continue
+			}
+
+			partnership, ok := partnerships[partnerId]
+			if !ok {
+				partnership = &Partnership{PartnerId: partnerId}
+				partnerships[partnerId] = partnership
+			}
+
+			partnership.Matches++
+			switch {
+			case m.Result == Draw:
+				partnership.Draws++
+			case won:
+				partnership.Wins++
+			default:
+				partnership.Losses++
+			}
+		}
+	}
+
+	records := make([]Partnership, 0, len(partnerships))
+	for _, partnership := range partnerships {
+		partnership.WinRate = float64(partnership.Wins) / float64(partnership.Matches)
+		records = append(records, *partnership)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Matches != records[j].Matches {
+			return records[i].Matches > records[j].Matches
+		}
+
+		return records[i].PartnerId < records[j].PartnerId
+	})
+
+	return records
+}
@@ -2,20 +2,39 @@ package match
 
 import (
 	"context"
+	"matchlog/pkg/database"
+	"strconv"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const ErrCodeMySQLDuplicateEntry uint16 = 1062
 
 var (
 	ErrDuplicateEntry = errors.New("already exists")
+	ErrNotFound       = errors.New("not found")
 )
 
 type Repository interface {
+	GetMatch(ctx context.Context, id uint) (*Match, error)
+	GetMatchForUpdate(ctx context.Context, id uint) (*Match, error)
+	GetMatchByIdempotencyKey(ctx context.Context, submittedBy uint, key string) (*Match, error)
+	GetConfirmedMatchesInOrder(ctx context.Context, clubId, gameId uint) ([]Match, error)
+	GetConfirmedMatchesBetween(ctx context.Context, clubId, gameId uint, from, to *time.Time) ([]Match, error)
+	GetLastConfirmedMatch(ctx context.Context, clubId, gameId uint) (*Match, error)
+	GetConfirmedMatchesOfPlayers(ctx context.Context, clubIds []uint, gameId uint, userIds []uint) ([]Match, error)
+	GetPendingMatchesBefore(ctx context.Context, before time.Time) ([]Match, error)
 	CreateMatch(ctx context.Context, match *Match) error
+	UpdateMatch(ctx context.Context, match *Match) error
+	DeleteMatch(ctx context.Context, id uint) error
+	ListMatches(ctx context.Context, filter ListFilter) ([]Match, error)
+	GetEvents(ctx context.Context, matchId uint) ([]Event, error)
+	CreateEvents(ctx context.Context, events []Event) error
+	DeleteEvents(ctx context.Context, matchId uint) error
 }
 
 type RepositoryImpl struct {
@@ -28,8 +47,158 @@ func NewRepository(db *gorm.DB) Repository {
 	}
 }
 
+func (r *RepositoryImpl) GetMatch(ctx context.Context, id uint) (*Match, error) {
+	var match Match
+	result := database.Conn(ctx, r.db).
+		First(&match, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &match, nil
+}
+
+// GetMatchForUpdate locks the match until the surrounding transaction ends.
+func (r *RepositoryImpl) GetMatchForUpdate(ctx context.Context, id uint) (*Match, error) {
+	var match Match
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&match, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &match, nil
+}
+
+func (r *RepositoryImpl) GetMatchByIdempotencyKey(ctx context.Context, submittedBy uint, key string) (*Match, error) {
+	var match Match
+	result := database.Conn(ctx, r.db).
+		Where("submitted_by = ? AND idempotency_key = ?", submittedBy, key).
+		First(&match)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &match, nil
+}
+
+// GetConfirmedMatchesInOrder returns confirmed matches in the order they were confirmed,
+// which is the order they were applied to statistics and ratings.
+func (r *RepositoryImpl) GetConfirmedMatchesInOrder(ctx context.Context, clubId, gameId uint) ([]Match, error) {
+	var matches []Match
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND status = ?", clubId, gameId, StatusConfirmed).
+		Order("confirmed_at asc, id asc").
+		Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return matches, nil
+}
+
+// GetPendingMatchesBefore returns the matches which have been pending since before the given time, longest pending first.
+func (r *RepositoryImpl) GetPendingMatchesBefore(ctx context.Context, before time.Time) ([]Match, error) {
+	var matches []Match
+	result := database.Conn(ctx, r.db).
+		Where("status = ? AND COALESCE(reopened_at, created_at) < ?", StatusPending, before).
+		Order("COALESCE(reopened_at, created_at) asc, id asc").
+		Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return matches, nil
+}
+
+// GetConfirmedMatchesBetween returns the confirmed matches played from up to but not including to, in the order they were confirmed.
+// A nil from or to leaves that end open.
+func (r *RepositoryImpl) GetConfirmedMatchesBetween(ctx context.Context, clubId, gameId uint, from, to *time.Time) ([]Match, error) {
+	query := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND status = ?", clubId, gameId, StatusConfirmed)
+
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	var matches []Match
+	result := query.
+		Order("confirmed_at asc, id asc").
+		Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return matches, nil
+}
+
+// GetLastConfirmedMatch returns the confirmed match that was played last.
+func (r *RepositoryImpl) GetLastConfirmedMatch(ctx context.Context, clubId, gameId uint) (*Match, error) {
+	var match Match
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND status = ?", clubId, gameId, StatusConfirmed).
+		Order("created_at desc, id desc").
+		First(&match)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &match, nil
+}
+
+// GetConfirmedMatchesOfPlayers returns the confirmed matches every one of the players took part in.
+// Only matches in the given clubs are returned, and a game of 0 matches any game.
+func (r *RepositoryImpl) GetConfirmedMatchesOfPlayers(ctx context.Context, clubIds []uint, gameId uint, userIds []uint) ([]Match, error) {
+	if len(clubIds) == 0 {
+		return nil, nil
+	}
+
+	query := database.Conn(ctx, r.db).
+		Where("status = ? AND club_id IN ?", StatusConfirmed, clubIds)
+
+	if gameId != 0 {
+		query = query.Where("game_id = ?", gameId)
+	}
+
+	for _, userId := range userIds {
+		player := strconv.FormatUint(uint64(userId), 10)
+		query = query.Where("(JSON_CONTAINS(team_a, ?) OR JSON_CONTAINS(team_b, ?))", player, player)
+	}
+
+	var matches []Match
+	result := query.
+		Order("confirmed_at asc, id asc").
+		Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return matches, nil
+}
+
 func (r *RepositoryImpl) CreateMatch(ctx context.Context, match *Match) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Create(&match)
 	if result.Error != nil {
 		var mysqlErr *mysql.MySQLError
@@ -42,3 +211,120 @@ func (r *RepositoryImpl) CreateMatch(ctx context.Context, match *Match) error {
 
 	return nil
 }
+
+func (r *RepositoryImpl) UpdateMatch(ctx context.Context, match *Match) error {
+	result := database.Conn(ctx, r.db).
+		Model(match).
+		Select("*").
+		Updates(match)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteMatch(ctx context.Context, id uint) error {
+	result := database.Conn(ctx, r.db).
+		Delete(&Match{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *RepositoryImpl) ListMatches(ctx context.Context, filter ListFilter) ([]Match, error) {
+	query := database.Conn(ctx, r.db).
+		Where("club_id = ?", filter.ClubId)
+
+	if filter.GameId != 0 {
+		query = query.Where("game_id = ?", filter.GameId)
+	}
+
+	if filter.PlayerId != 0 {
+		player := strconv.FormatUint(uint64(filter.PlayerId), 10)
+		query = query.Where("(JSON_CONTAINS(team_a, ?) OR JSON_CONTAINS(team_b, ?))", player, player)
+
+		if filter.OpponentId != 0 {
+			opponent := strconv.FormatUint(uint64(filter.OpponentId), 10)
+			query = query.Where(
+				"((JSON_CONTAINS(team_a, ?) AND JSON_CONTAINS(team_b, ?)) OR (JSON_CONTAINS(team_b, ?) AND JSON_CONTAINS(team_a, ?)))",
+				player, opponent, player, opponent,
+			)
+		}
+
+		if filter.PartnerId != 0 {
+			partner := strconv.FormatUint(uint64(filter.PartnerId), 10)
+			query = query.Where(
+				"((JSON_CONTAINS(team_a, ?) AND JSON_CONTAINS(team_a, ?)) OR (JSON_CONTAINS(team_b, ?) AND JSON_CONTAINS(team_b, ?)))",
+				player, partner, player, partner,
+			)
+		}
+	}
+
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	if filter.Rated != nil {
+		query = query.Where("rated = ?", *filter.Rated)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.after != nil {
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", filter.after.createdAt, filter.after.createdAt, filter.after.id)
+	}
+
+	var matches []Match
+	result := query.
+		Order("created_at desc, id desc").
+		Limit(filter.Limit).
+		Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return matches, nil
+}
+
+func (r *RepositoryImpl) GetEvents(ctx context.Context, matchId uint) ([]Event, error) {
+	var events []Event
+	result := database.Conn(ctx, r.db).
+		Where("match_id = ?", matchId).
+		Order("sequence asc").
+		Find(&events)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return events, nil
+}
+
+func (r *RepositoryImpl) CreateEvents(ctx context.Context, events []Event) error {
+	result := database.Conn(ctx, r.db).
+		Create(&events)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteEvents(ctx context.Context, matchId uint) error {
+	result := database.Conn(ctx, r.db).
+		Where("match_id = ?", matchId).
+		Delete(&Event{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
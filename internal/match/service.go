@@ -3,45 +3,725 @@ package match
 import (
 	"context"
 	"fmt"
+	"matchlog/internal/achievement"
+	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/rating"
+	"matchlog/internal/statistic"
+	"matchlog/internal/team"
+	"matchlog/pkg/database"
+	"matchlog/pkg/pubsub"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+var (
+	ErrNotPending = errors.New("match is not pending")
+	ErrNotAllowed = errors.New("user is not allowed to respond to match")
+
+	ErrGameNotInClub = errors.New("game is not played in club")
+	ErrNotClubMember = errors.New("player is not a member of club")
+
+	ErrInvalidSubmission = errors.New("submission does not fit the type of game")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different submission")
+)
+
 type Service interface {
-	CreateMatch(ctx context.Context, teamA, teamB []uint, scoresA, scoresB []int, result Result) error
+	GetMatch(ctx context.Context, id uint) (*Match, error)
+	CreateMatch(ctx context.Context, submission Submission) (*Match, error)
+	ImportMatches(ctx context.Context, submissions []Submission) error
+	UpdateMatch(ctx context.Context, id uint, correction Submission, moderated bool) error
+	DeleteMatch(ctx context.Context, id uint, moderated bool) (deleted bool, err error)
+	ConfirmMatch(ctx context.Context, id uint, userId uint) error
+	DisputeMatch(ctx context.Context, id uint, userId uint) error
+	ConfirmExpiredMatches(ctx context.Context, timeout time.Duration) (confirmed int, err error)
+	ListMatches(ctx context.Context, filter ListFilter) (matches []Match, nextCursor string, err error)
+	GetEvents(ctx context.Context, id uint) ([]Event, error)
+	GetSummary(ctx context.Context, id uint) (*Summary, error)
+	Recalculate(ctx context.Context, clubId, gameId uint) error
+	GetStatisticsBetween(ctx context.Context, clubId, gameId uint, from, to *time.Time) ([]statistic.Statistic, error)
+	GetLastPlayedAt(ctx context.Context, clubId, gameId uint) (*time.Time, error)
+	GetHeadToHead(ctx context.Context, clubIds []uint, gameId uint, userId, opponentId uint) (*HeadToHead, error)
+	GetPartners(ctx context.Context, clubIds []uint, gameId uint, userId uint) ([]Partnership, error)
 	DetermineResult(ctx context.Context, teamA, teamB []uint, scoresA, scoresB []int) (result Result, winners []uint, losers []uint)
+	SubscribeToStandings(clubId, gameId uint, buffer int) (changes <-chan StandingsChanged, unsubscribe func())
+	OnStandingsChanged(hook func(StandingsChanged))
 }
 
 type ServiceImpl struct {
-	repo Repository
+	repo               Repository
+	transactor         database.Transactor
+	clubService        club.Service
+	gameService        game.Service
+	teamService        team.Service
+	statisticService   statistic.Service
+	ratingService      rating.Service
+	achievementService achievement.Service
+
+	standings *pubsub.Broker[StandingsChanged]
 }
 
-func NewService(repo Repository) Service {
+func NewService(repo Repository, transactor database.Transactor, clubService club.Service, gameService game.Service, teamService team.Service, statisticService statistic.Service, ratingService rating.Service, achievementService achievement.Service) Service {
 	return &ServiceImpl{
-		repo: repo,
+		repo:               repo,
+		transactor:         transactor,
+		clubService:        clubService,
+		gameService:        gameService,
+		teamService:        teamService,
+		statisticService:   statisticService,
+		ratingService:      ratingService,
+		achievementService: achievementService,
+		standings:          pubsub.NewBroker[StandingsChanged](),
 	}
 }
 
-func (s *ServiceImpl) CreateMatch(ctx context.Context, teamA, teamB []uint, scoresA, scoresB []int, result Result) error {
-	sets := make([]string, len(scoresA))
-	for i, scoreA := range scoresA {
-		sets[i] = fmt.Sprintf("%d-%d", scoreA, scoresB[i])
+func (s *ServiceImpl) GetMatch(ctx context.Context, id uint) (*Match, error) {
+	match, err := s.repo.GetMatch(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get match %d", id)
+	}
+
+	return match, nil
+}
+
+// CreateMatch stores the match as pending.
+// It is not applied to statistics and ratings until it is confirmed by the opposing side.
+//
+// A team match is submitted as the players and set scores of team A and team B.
+// A free-for-all match is submitted as its players and either their placements or their final scores,
+// and a cooperative match as its players and whether they won. Cooperative matches are never rated,
+// as there is no one to be rated against.
+//
+// A submission with an idempotency key the submitter used before is not recorded again,
+// the match recorded the first time is returned instead. The key can not be reused for a different submission.
+func (s *ServiceImpl) CreateMatch(ctx context.Context, submission Submission) (*Match, error) {
+	var submissionHash string
+	if submission.IdempotencyKey != "" {
+		var err error
+		submissionHash, err = submission.hash()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to hash submission")
+		}
+
+		match, err := s.getMatchByIdempotencyKey(ctx, submission, submissionHash)
+		if err == nil {
+			return match, nil
+		}
+
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	var created *Match
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		g, err := s.getGameInClub(ctx, submission.ClubId, submission.GameId)
+		if err != nil {
+			return err
+		}
+
+		if err := s.validatePlayers(ctx, submission.ClubId, append([]uint{submission.SubmittedBy}, submission.players()...)); err != nil {
+			return err
+		}
+
+		match, err := s.buildSubmittedMatch(ctx, g, submission)
+		if err != nil {
+			return err
+		}
+
+		match.Status = StatusPending
+		if submission.IdempotencyKey != "" {
+			match.IdempotencyKey = &submission.IdempotencyKey
+			match.SubmissionHash = submissionHash
+		}
+
+		if err := s.createMatch(ctx, match, submission.Events); err != nil {
+			return err
+		}
+
+		created = match
+
+		return nil
+	})
+
+	// A retry running at the same time got there first.
+	if errors.Is(err, ErrDuplicateEntry) && submission.IdempotencyKey != "" {
+		return s.getMatchByIdempotencyKey(ctx, submission, submissionHash)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// getMatchByIdempotencyKey returns the match recorded with the idempotency key of the submission, if it was recorded from the same submission.
+// Matches recorded before submissions were fingerprinted are taken to be.
+func (s *ServiceImpl) getMatchByIdempotencyKey(ctx context.Context, submission Submission, submissionHash string) (*Match, error) {
+	match, err := s.repo.GetMatchByIdempotencyKey(ctx, submission.SubmittedBy, submission.IdempotencyKey)
+	if errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get match by idempotency key")
+	}
+
+	if match.SubmissionHash != "" && match.SubmissionHash != submissionHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	return match, nil
+}
+
+// ImportMatches records historical matches as confirmed at the time they were played,
+// and replays statistics and ratings of the games they were played in, so everything is applied in chronological order.
+// Either every match is imported or none of them are.
+func (s *ServiceImpl) ImportMatches(ctx context.Context, submissions []Submission) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		games := map[[2]uint]*game.Game{}
+		imported := map[[2]uint][]*Match{}
+
+		for i, submission := range submissions {
+			key := [2]uint{submission.ClubId, submission.GameId}
+
+			g, ok := games[key]
+			if !ok {
+				var err error
+				g, err = s.getGameInClub(ctx, submission.ClubId, submission.GameId)
+				if err != nil {
+					return errors.Wrapf(err, "match %d", i+1)
+				}
+
+				games[key] = g
+			}
+
+			if err := s.validatePlayers(ctx, submission.ClubId, submission.players()); err != nil {
+				return errors.Wrapf(err, "match %d", i+1)
+			}
+
+			match, err := s.buildSubmittedMatch(ctx, g, submission)
+			if err != nil {
+				return errors.Wrapf(err, "match %d", i+1)
+			}
+
+			playedAt := submission.PlayedAt
+			if playedAt.IsZero() {
+				playedAt = time.Now()
+			}
+
+			match.Status = StatusConfirmed
+			match.ConfirmedBy = submission.SubmittedBy
+			match.ConfirmedAt = &playedAt
+			match.CreatedAt = playedAt
+
+			if err := s.createMatch(ctx, match, submission.Events); err != nil {
+				return errors.Wrapf(err, "match %d", i+1)
+			}
+
+			imported[key] = append(imported[key], match)
+		}
+
+		for key, matches := range imported {
+			if err := s.recalculate(ctx, key[0], key[1], matches...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// buildSubmittedMatch builds a match from a submission, after checking it against the type and rules of the game.
+func (s *ServiceImpl) buildSubmittedMatch(ctx context.Context, g *game.Game, submission Submission) (*Match, error) {
+	var match *Match
+	var err error
+
+	switch g.Type {
+	case game.FreeForAllGameType:
+		match, err = s.buildPlacedMatch(g, submission)
+	case game.CoopGameType:
+		match, err = s.buildCoopMatch(ctx, submission)
+	default:
+		match, err = s.buildTeamMatch(ctx, g, submission)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	match.Type = g.Type
+	match.GameId = submission.GameId
+	match.SubmittedBy = submission.SubmittedBy
+	match.Rated = submission.Rated
+
+	if err := validateEvents(match, submission.Events); err != nil {
+		return nil, err
+	}
+
+	return match, nil
+}
+
+func (s *ServiceImpl) buildTeamMatch(ctx context.Context, g *game.Game, submission Submission) (*Match, error) {
+	if len(submission.TeamA) == 0 || len(submission.TeamB) == 0 || len(submission.Players) > 0 ||
+		len(submission.Placements) > 0 || len(submission.Scores) > 0 || submission.Won != nil {
+		return nil, errors.Wrap(ErrInvalidSubmission, "a team match is played between team A and team B")
+	}
+
+	if err := g.ValidateSets(submission.ScoresA, submission.ScoresB); err != nil {
+		return nil, err
+	}
+
+	return s.buildMatch(ctx, submission.ClubId, submission.TeamA, submission.TeamB, submission.ScoresA, submission.ScoresB)
+}
+
+// buildPlacedMatch builds a free-for-all match, deriving the placements from the scores if it was scored.
+func (s *ServiceImpl) buildPlacedMatch(g *game.Game, submission Submission) (*Match, error) {
+	if len(submission.TeamA) > 0 || len(submission.TeamB) > 0 || len(submission.ScoresA) > 0 || len(submission.ScoresB) > 0 ||
+		submission.Won != nil || len(submission.Events) > 0 {
+		return nil, errors.Wrap(ErrInvalidSubmission, "a free-for-all match has players with placements or scores")
+	}
+
+	placements := submission.Placements
+	if len(submission.Scores) > 0 {
+		if len(placements) > 0 {
+			return nil, errors.Wrap(ErrInvalidSubmission, "a free-for-all match has either placements or scores")
+		}
+
+		placements = placementsFromScores(submission.Scores)
+	}
+
+	if len(placements) != len(submission.Players) {
+		return nil, errors.Wrap(ErrInvalidSubmission, "every player needs a placement or score")
+	}
+
+	for i, userId := range submission.Players {
+		if contains(submission.Players[:i], userId) {
+			return nil, errors.Wrapf(ErrInvalidSubmission, "user %d is listed more than once", userId)
+		}
+	}
+
+	if err := g.ValidatePlacements(placements); err != nil {
+		return nil, err
 	}
 
 	match := &Match{
-		TeamA:  teamA,
-		TeamB:  teamB,
-		Sets:   sets,
-		Result: result,
+		ClubId:     submission.ClubId,
+		TeamA:      submission.Players,
+		TeamB:      []uint{},
+		Sets:       []string{},
+		Result:     Placed,
+		Placements: placements,
+		Scores:     submission.Scores,
 	}
 
+	return match, nil
+}
+
+// buildCoopMatch builds a cooperative match, in which the players are won or lost against the game.
+func (s *ServiceImpl) buildCoopMatch(ctx context.Context, submission Submission) (*Match, error) {
+	if len(submission.TeamA) > 0 || len(submission.TeamB) > 0 || len(submission.ScoresA) > 0 || len(submission.ScoresB) > 0 ||
+		len(submission.Placements) > 0 || len(submission.Scores) > 0 || len(submission.Events) > 0 {
+		return nil, errors.Wrap(ErrInvalidSubmission, "a cooperative match has players who won or lost")
+	}
+
+	if len(submission.Players) == 0 || submission.Won == nil {
+		return nil, errors.Wrap(ErrInvalidSubmission, "a cooperative match needs its players and whether they won")
+	}
+
+	teamId, err := s.resolveTeam(ctx, submission.ClubId, submission.Players)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve team")
+	}
+
+	result := TeamBWins
+	if *submission.Won {
+		result = TeamAWins
+	}
+
+	match := &Match{
+		ClubId:  submission.ClubId,
+		TeamA:   submission.Players,
+		TeamB:   []uint{},
+		TeamAId: teamId,
+		Sets:    []string{},
+		Result:  result,
+	}
+
+	return match, nil
+}
+
+// createMatch stores the match along with its event log.
+func (s *ServiceImpl) createMatch(ctx context.Context, match *Match, events []Event) error {
 	if err := s.repo.CreateMatch(ctx, match); err != nil {
 		return errors.Wrap(err, "failed to create match")
 	}
 
+	if len(events) == 0 {
+		return nil
+	}
+
+	logged := make([]Event, len(events))
+	for i, event := range events {
+		event.MatchId = match.Id
+		event.Sequence = i + 1
+		logged[i] = event
+	}
+
+	if err := s.repo.CreateEvents(ctx, logged); err != nil {
+		return errors.Wrap(err, "failed to create match events")
+	}
+
 	return nil
 }
 
+// ConfirmMatch confirms a pending match on behalf of the opposing side, and applies it to statistics and ratings.
+// Either all of it is recorded or none of it is.
+func (s *ServiceImpl) ConfirmMatch(ctx context.Context, id uint, userId uint) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		match, err := s.repo.GetMatchForUpdate(ctx, id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get match %d", id)
+		}
+
+		if match.Status != StatusPending {
+			return ErrNotPending
+		}
+
+		if !match.CanConfirm(userId) {
+			return ErrNotAllowed
+		}
+
+		return s.confirm(ctx, match, userId)
+	})
+}
+
+// DisputeMatch marks a pending match as disputed by one of its players.
+// A disputed match is never applied, and goes back to pending once it has been corrected.
+func (s *ServiceImpl) DisputeMatch(ctx context.Context, id uint, userId uint) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		match, err := s.repo.GetMatchForUpdate(ctx, id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get match %d", id)
+		}
+
+		if match.Status != StatusPending {
+			return ErrNotPending
+		}
+
+		if !match.IsPlayer(userId) {
+			return ErrNotAllowed
+		}
+
+		match.Status = StatusDisputed
+		match.DisputedBy = userId
+
+		if err := s.repo.UpdateMatch(ctx, match); err != nil {
+			return errors.Wrapf(err, "failed to dispute match %d", id)
+		}
+
+		return nil
+	})
+}
+
+// ConfirmExpiredMatches confirms every match that has been pending for longer than the timeout, oldest first.
+// A match failing to be confirmed does not hold up the others, the failures are returned together after all of them were tried.
+func (s *ServiceImpl) ConfirmExpiredMatches(ctx context.Context, timeout time.Duration) (int, error) {
+	matches, err := s.repo.GetPendingMatchesBefore(ctx, time.Now().Add(-timeout))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get expired pending matches")
+	}
+
+	confirmed := 0
+	var failures []string
+	for _, m := range matches {
+		applied := false
+		err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+			// It may have been confirmed, disputed or deleted since it was listed.
+			match, err := s.repo.GetMatchForUpdate(ctx, m.Id)
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+
+			if err != nil {
+				return errors.Wrapf(err, "failed to get match %d", m.Id)
+			}
+
+			if match.Status != StatusPending {
+				return nil
+			}
+
+			if err := s.confirm(ctx, match, 0); err != nil {
+				return err
+			}
+
+			applied = true
+
+			return nil
+		})
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("match %d: %v", m.Id, err))
+			continue
+		}
+
+		if applied {
+			confirmed++
+		}
+	}
+
+	if len(failures) > 0 {
+		return confirmed, errors.Errorf("failed to auto-confirm %d of %d matches: %s", len(failures), len(matches), strings.Join(failures, "; "))
+	}
+
+	return confirmed, nil
+}
+
+// UpdateMatch corrects the result of a match, which is submitted the same way as a new match,
+// and recalculates statistics and ratings as if it had been recorded correctly in the first place.
+// Unless the correction is moderated by a manager or admin of the club, a confirmed match goes back to pending,
+// so the opposing side has to confirm the corrected result before it is applied.
+func (s *ServiceImpl) UpdateMatch(ctx context.Context, id uint, correction Submission, moderated bool) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		oldMatch, err := s.repo.GetMatchForUpdate(ctx, id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get match %d", id)
+		}
+
+		g, err := s.gameService.GetGame(ctx, oldMatch.GameId)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get game %d", oldMatch.GameId)
+		}
+
+		if err := s.validatePlayers(ctx, oldMatch.ClubId, correction.players()); err != nil {
+			return err
+		}
+
+		correction.ClubId = oldMatch.ClubId
+		correction.GameId = oldMatch.GameId
+		correction.SubmittedBy = oldMatch.SubmittedBy
+		correction.Events = nil
+
+		match, err := s.buildSubmittedMatch(ctx, g, correction)
+		if err != nil {
+			return err
+		}
+
+		match.Id = oldMatch.Id
+		match.Status = oldMatch.Status
+		match.ConfirmedBy = oldMatch.ConfirmedBy
+		match.ConfirmedAt = oldMatch.ConfirmedAt
+		match.CreatedAt = oldMatch.CreatedAt
+
+		// A corrected dispute has to be confirmed again.
+		if oldMatch.Status == StatusDisputed || (oldMatch.Status == StatusConfirmed && !moderated) {
+			reopen(match)
+		}
+
+		if err := s.repo.UpdateMatch(ctx, match); err != nil {
+			return errors.Wrapf(err, "failed to update match %d", id)
+		}
+
+		// The event log is dropped if the correction contradicts it.
+		events, err := s.repo.GetEvents(ctx, id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get events of match %d", id)
+		}
+
+		if len(events) > 0 && validateEvents(match, events) != nil {
+			if err := s.repo.DeleteEvents(ctx, id); err != nil {
+				return errors.Wrapf(err, "failed to delete events of match %d", id)
+			}
+		}
+
+		if oldMatch.Status != StatusConfirmed {
+			return nil
+		}
+
+		return s.recalculate(ctx, oldMatch.ClubId, oldMatch.GameId, oldMatch)
+	})
+}
+
+// DeleteMatch removes a match, and recalculates statistics and ratings as if it had never been recorded.
+// Unless the deletion is moderated by a manager or admin of the club, a confirmed match is not removed,
+// but taken out of statistics and ratings and sent back to pending, where the opposing side can confirm it again.
+// Whether the match was removed is returned.
+func (s *ServiceImpl) DeleteMatch(ctx context.Context, id uint, moderated bool) (bool, error) {
+	deleted := false
+	err := s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		oldMatch, err := s.repo.GetMatchForUpdate(ctx, id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get match %d", id)
+		}
+
+		if oldMatch.Status == StatusConfirmed && !moderated {
+			match := *oldMatch
+			reopen(&match)
+			if err := s.repo.UpdateMatch(ctx, &match); err != nil {
+				return errors.Wrapf(err, "failed to reopen match %d", id)
+			}
+
+			return s.recalculate(ctx, oldMatch.ClubId, oldMatch.GameId, oldMatch)
+		}
+
+		if err := s.repo.DeleteEvents(ctx, id); err != nil {
+			return errors.Wrapf(err, "failed to delete events of match %d", id)
+		}
+
+		if err := s.repo.DeleteMatch(ctx, id); err != nil {
+			return errors.Wrapf(err, "failed to delete match %d", id)
+		}
+
+		deleted = true
+
+		if oldMatch.Status != StatusConfirmed {
+			return nil
+		}
+
+		return s.recalculate(ctx, oldMatch.ClubId, oldMatch.GameId, oldMatch)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deleted, nil
+}
+
+// reopen sends the match back to pending, to be confirmed or disputed again.
+func reopen(match *Match) {
+	now := time.Now()
+	match.Status = StatusPending
+	match.ConfirmedBy = 0
+	match.ConfirmedAt = nil
+	match.DisputedBy = 0
+	match.ReopenedAt = &now
+}
+
+func (s *ServiceImpl) ListMatches(ctx context.Context, filter ListFilter) ([]Match, string, error) {
+	if filter.Cursor != "" {
+		after, err := decodeListCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		filter.after = after
+	}
+
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	matches, err := s.repo.ListMatches(ctx, filter)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to list matches in club %d", filter.ClubId)
+	}
+
+	if len(matches) <= limit {
+		return matches, "", nil
+	}
+
+	matches = matches[:limit]
+
+	return matches, encodeListCursor(matches[limit-1]), nil
+}
+
+func (s *ServiceImpl) GetEvents(ctx context.Context, id uint) ([]Event, error) {
+	events, err := s.repo.GetEvents(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get events of match %d", id)
+	}
+
+	return events, nil
+}
+
+// GetSummary derives goals per player, comebacks and shutouts from the event log of a match.
+// A match without an event log has an empty summary.
+func (s *ServiceImpl) GetSummary(ctx context.Context, id uint) (*Summary, error) {
+	match, err := s.repo.GetMatch(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get match %d", id)
+	}
+
+	events, err := s.repo.GetEvents(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get events of match %d", id)
+	}
+
+	summary := Summarize(match, events)
+
+	return &summary, nil
+}
+
+// GetLastPlayedAt returns when the last confirmed match of the game in the club was played, or nil if none was.
+func (s *ServiceImpl) GetLastPlayedAt(ctx context.Context, clubId, gameId uint) (*time.Time, error) {
+	match, err := s.repo.GetLastConfirmedMatch(ctx, clubId, gameId)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get last confirmed match")
+	}
+
+	return &match.CreatedAt, nil
+}
+
+// GetStatisticsBetween computes the statistics of the players and teams of the game in the club
+// from the confirmed matches played from up to but not including to. A nil from or to leaves that end open.
+func (s *ServiceImpl) GetStatisticsBetween(ctx context.Context, clubId, gameId uint, from, to *time.Time) ([]statistic.Statistic, error) {
+	matches, err := s.repo.GetConfirmedMatchesBetween(ctx, clubId, gameId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get matches to tally")
+	}
+
+	outcomes := make([]statistic.Outcome, len(matches))
+	for i := range matches {
+		outcomes[i], err = statisticOutcome(&matches[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return statistic.Tally(clubId, gameId, outcomes), nil
+}
+
+// Recalculate replays the statistics and ratings of the game in the club from its confirmed matches.
+func (s *ServiceImpl) Recalculate(ctx context.Context, clubId, gameId uint) error {
+	return s.transactor.Transaction(ctx, func(ctx context.Context) error {
+		return s.recalculate(ctx, clubId, gameId)
+	})
+}
+
+// GetHeadToHead derives the record of a player against an opponent from the confirmed matches they played against each other.
+// Only matches in the given clubs count, and a game of 0 counts matches in every game.
+func (s *ServiceImpl) GetHeadToHead(ctx context.Context, clubIds []uint, gameId uint, userId, opponentId uint) (*HeadToHead, error) {
+	matches, err := s.repo.GetConfirmedMatchesOfPlayers(ctx, clubIds, gameId, []uint{userId, opponentId})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get matches of users %d and %d", userId, opponentId)
+	}
+
+	record, err := HeadToHeadRecord(userId, opponentId, matches)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// GetPartners derives the records of a player with each of their teammates from the confirmed matches they played together.
+// Only matches in the given clubs count, and a game of 0 counts matches in every game.
+func (s *ServiceImpl) GetPartners(ctx context.Context, clubIds []uint, gameId uint, userId uint) ([]Partnership, error) {
+	matches, err := s.repo.GetConfirmedMatchesOfPlayers(ctx, clubIds, gameId, []uint{userId})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get matches of user %d", userId)
+	}
+
+	return PartnerRecords(userId, matches), nil
+}
+
 func (s *ServiceImpl) DetermineResult(ctx context.Context, teamA, teamB []uint, scoresA, scoresB []int) (Result, []uint, []uint) {
 	teamASetWins := 0
 	teamBSetWins := 0
@@ -63,3 +743,414 @@ func (s *ServiceImpl) DetermineResult(ctx context.Context, teamA, teamB []uint,
 	}
 
 }
+
+// confirm marks the match as confirmed and applies it to the statistics and ratings of the players and teams involved,
+// after which the players are awarded the achievements they earned with it.
+// A confirming user of 0 means the match was confirmed automatically.
+func (s *ServiceImpl) confirm(ctx context.Context, match *Match, userId uint) error {
+	now := time.Now()
+	match.Status = StatusConfirmed
+	match.ConfirmedBy = userId
+	match.ConfirmedAt = &now
+
+	if err := s.repo.UpdateMatch(ctx, match); err != nil {
+		return errors.Wrapf(err, "failed to confirm match %d", match.Id)
+	}
+
+	if err := s.ensureStandings(ctx, match.ClubId, match.GameId, match.players(), match.teamIds()); err != nil {
+		return err
+	}
+
+	// Who was the highest rated player is needed from before the match changes it.
+	topRatedUserId, err := s.getTopRatedUserId(ctx, match.ClubId, match.GameId)
+	if err != nil {
+		return err
+	}
+
+	outcome, err := statisticOutcome(match)
+	if err != nil {
+		return err
+	}
+
+	if err := s.statisticService.ApplyOutcome(ctx, match.ClubId, match.GameId, outcome); err != nil {
+		return errors.Wrap(err, "failed to update statistics")
+	}
+
+	if match.Rated {
+		if err := s.ratingService.ApplyOutcome(ctx, match.ClubId, match.GameId, ratingOutcome(match)); err != nil {
+			return errors.Wrap(err, "failed to update ratings")
+		}
+	}
+
+	result, err := achievementResult(match, topRatedUserId)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.achievementService.Evaluate(ctx, result); err != nil {
+		return errors.Wrap(err, "failed to evaluate achievements")
+	}
+
+	s.publishStandingsChanged(ctx, match.ClubId, match.GameId)
+
+	return nil
+}
+
+// SubscribeToStandings returns a channel receiving the changes to the standings of the game in the club,
+// holding up to buffer changes that have not been picked up. A club or game of 0 receives the changes in every club or game.
+func (s *ServiceImpl) SubscribeToStandings(clubId, gameId uint, buffer int) (<-chan StandingsChanged, func()) {
+	return s.standings.Subscribe(func(change StandingsChanged) bool {
+		return (clubId == 0 || change.ClubId == clubId) && (gameId == 0 || change.GameId == gameId)
+	}, buffer)
+}
+
+// OnStandingsChanged runs the hook on every change to the standings as it is published,
+// before subscribers receive it.
+func (s *ServiceImpl) OnStandingsChanged(hook func(StandingsChanged)) {
+	s.standings.OnPublish(hook)
+}
+
+// publishStandingsChanged lets subscribers know about the change once the transaction making it commits.
+func (s *ServiceImpl) publishStandingsChanged(ctx context.Context, clubId, gameId uint) {
+	database.AfterCommit(ctx, func() {
+		s.standings.Publish(StandingsChanged{ClubId: clubId, GameId: gameId})
+	})
+}
+
+// getTopRatedUserId returns the highest rated member of the club in the game,
+// or 0 if no one is rated higher than everyone else, as is the case before anyone has played.
+func (s *ServiceImpl) getTopRatedUserId(ctx context.Context, clubId, gameId uint) (uint, error) {
+	userIds, err := s.clubService.GetUserIdsInClub(ctx, clubId)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get users in club %d", clubId)
+	}
+
+	topUserIds, ratings, err := s.ratingService.GetTopXAmongUserIdsByRating(ctx, clubId, gameId, 2, 0, userIds)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get highest rated user")
+	}
+
+	if len(topUserIds) == 0 || (len(ratings) > 1 && ratings[0] == ratings[1]) {
+		return 0, nil
+	}
+
+	return topUserIds[0], nil
+}
+
+// buildMatch resolves the teams and result of a match from the players and scores of each side.
+func (s *ServiceImpl) buildMatch(ctx context.Context, clubId uint, teamA, teamB []uint, scoresA, scoresB []int) (*Match, error) {
+	teamAId, err := s.resolveTeam(ctx, clubId, teamA)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve team A")
+	}
+
+	teamBId, err := s.resolveTeam(ctx, clubId, teamB)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve team B")
+	}
+
+	result, _, _ := s.DetermineResult(ctx, teamA, teamB, scoresA, scoresB)
+
+	sets := make([]string, len(scoresA))
+	for i, scoreA := range scoresA {
+		sets[i] = fmt.Sprintf("%d-%d", scoreA, scoresB[i])
+	}
+
+	match := &Match{
+		ClubId:  clubId,
+		TeamA:   teamA,
+		TeamB:   teamB,
+		TeamAId: teamAId,
+		TeamBId: teamBId,
+		Sets:    sets,
+		Result:  result,
+	}
+
+	return match, nil
+}
+
+// resolveTeam looks up the team made up of the given players, creating it on first appearance.
+// Sides with a single player are not teams, and resolve to 0.
+func (s *ServiceImpl) resolveTeam(ctx context.Context, clubId uint, userIds []uint) (uint, error) {
+	if len(userIds) < 2 {
+		return 0, nil
+	}
+
+	t, _, err := s.teamService.GetOrCreateTeam(ctx, clubId, userIds)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get or create team")
+	}
+
+	return t.Id, nil
+}
+
+// getGameInClub gets the game, if it is one of the games played in the club.
+func (s *ServiceImpl) getGameInClub(ctx context.Context, clubId, gameId uint) (*game.Game, error) {
+	gameIds, err := s.clubService.GetGameIdsInClub(ctx, clubId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get games in club %d", clubId)
+	}
+
+	if !contains(gameIds, gameId) {
+		return nil, ErrGameNotInClub
+	}
+
+	g, err := s.gameService.GetGame(ctx, gameId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get game %d", gameId)
+	}
+
+	return g, nil
+}
+
+// validatePlayers checks that every player is an accepted member of the club.
+func (s *ServiceImpl) validatePlayers(ctx context.Context, clubId uint, userIds []uint) error {
+	for _, userId := range userIds {
+		_, err := s.clubService.GetMembership(ctx, userId, clubId)
+		if errors.Is(err, club.ErrNotFound) {
+			return errors.Wrapf(ErrNotClubMember, "user %d", userId)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to get membership of user %d", userId)
+		}
+	}
+
+	return nil
+}
+
+// ensureStandings creates the statistics and ratings of players and teams playing the game in the club for the first time.
+func (s *ServiceImpl) ensureStandings(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) error {
+	if err := s.statisticService.EnsureStatistics(ctx, clubId, gameId, userIds, teamIds); err != nil {
+		return errors.Wrap(err, "failed to ensure statistics")
+	}
+
+	if err := s.ratingService.EnsureRatings(ctx, clubId, gameId, userIds, teamIds); err != nil {
+		return errors.Wrap(err, "failed to ensure ratings")
+	}
+
+	return nil
+}
+
+// recalculate resets statistics and ratings of the game in the club, and replays every confirmed match there
+// in the order they were confirmed. The badges of the players of the changed matches are evaluated again along the way.
+// The players and teams of the changed matches are always reset, even if they no longer have any matches.
+func (s *ServiceImpl) recalculate(ctx context.Context, clubId, gameId uint, changed ...*Match) error {
+	matches, err := s.repo.GetConfirmedMatchesInOrder(ctx, clubId, gameId)
+	if err != nil {
+		return errors.Wrap(err, "failed to get matches to replay")
+	}
+
+	userIds := map[uint]struct{}{}
+	teamIds := map[uint]struct{}{}
+	statisticOutcomes := make([]statistic.Outcome, 0, len(matches))
+	ratingOutcomes := make([]rating.Outcome, 0, len(matches))
+
+	for _, m := range changed {
+		for _, userId := range m.players() {
+			userIds[userId] = struct{}{}
+		}
+
+		for _, teamId := range m.teamIds() {
+			teamIds[teamId] = struct{}{}
+		}
+	}
+
+	for _, m := range matches {
+		for _, userId := range m.players() {
+			userIds[userId] = struct{}{}
+		}
+
+		for _, teamId := range m.teamIds() {
+			teamIds[teamId] = struct{}{}
+		}
+	}
+
+	// A correction may bring in players or teams that have not played the game in the club before.
+	if err := s.ensureStandings(ctx, clubId, gameId, keys(userIds), keys(teamIds)); err != nil {
+		return err
+	}
+
+	for i := range matches {
+		outcome, err := statisticOutcome(&matches[i])
+		if err != nil {
+			return err
+		}
+
+		statisticOutcomes = append(statisticOutcomes, outcome)
+
+		if !matches[i].Rated {
+			continue
+		}
+
+		ratingOutcomes = append(ratingOutcomes, ratingOutcome(&matches[i]))
+	}
+
+	if err := s.statisticService.RecalculateStatistics(ctx, clubId, gameId, keys(userIds), keys(teamIds), statisticOutcomes); err != nil {
+		return errors.Wrap(err, "failed to recalculate statistics")
+	}
+
+	if err := s.ratingService.RecalculateRatings(ctx, clubId, gameId, keys(userIds), keys(teamIds), ratingOutcomes); err != nil {
+		return errors.Wrap(err, "failed to recalculate ratings")
+	}
+
+	if err := s.reevaluateAchievements(ctx, clubId, gameId, changed, matches, statisticOutcomes); err != nil {
+		return err
+	}
+
+	s.publishStandingsChanged(ctx, clubId, gameId)
+
+	return nil
+}
+
+// reevaluateAchievements evaluates the badges of the players of the changed matches again, as they were before and after the change,
+// against every confirmed match in order, with the statistics replayed up to that match.
+func (s *ServiceImpl) reevaluateAchievements(ctx context.Context, clubId, gameId uint, changed []*Match, confirmed []Match, outcomes []statistic.Outcome) error {
+	userIds := map[uint]struct{}{}
+	changedIds := make(map[uint]struct{}, len(changed))
+	for _, m := range changed {
+		changedIds[m.Id] = struct{}{}
+
+		for _, userId := range m.players() {
+			userIds[userId] = struct{}{}
+		}
+	}
+
+	statsAfter := statistic.TallyEach(clubId, gameId, outcomes)
+
+	results := make([]achievement.MatchResult, 0, len(confirmed))
+	for i := range confirmed {
+		if _, ok := changedIds[confirmed[i].Id]; ok {
+			for _, userId := range confirmed[i].players() {
+				userIds[userId] = struct{}{}
+			}
+		}
+
+		result, err := achievementResult(&confirmed[i], 0)
+		if err != nil {
+			return err
+		}
+
+		result.Statistics = statsAfter[i]
+		results = append(results, result)
+	}
+
+	if err := s.achievementService.Reevaluate(ctx, clubId, gameId, keys(userIds), keys(changedIds), results); err != nil {
+		return errors.Wrap(err, "failed to reevaluate achievements")
+	}
+
+	return nil
+}
+
+func keys(set map[uint]struct{}) []uint {
+	ids := make([]uint, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func statisticOutcome(m *Match) (statistic.Outcome, error) {
+	winners, losers, winningTeamId, losingTeamId := m.Sides()
+
+	outcome := statistic.Outcome{
+		Draw:          m.Result == Draw,
+		WinnerIds:     winners,
+		LoserIds:      losers,
+		WinningTeamId: winningTeamId,
+		LosingTeamId:  losingTeamId,
+		Placements:    m.PlacementsByUserId(),
+		PlayedAt:      m.CreatedAt,
+	}
+
+	scoresA, scoresB, err := m.scores()
+	if err != nil {
+		return statistic.Outcome{}, err
+	}
+
+	// Sides returns team A as the winning side unless team B won.
+	if m.Result == TeamBWins {
+		scoresA, scoresB = scoresB, scoresA
+	}
+
+	for i := range scoresA {
+		outcome.WinnerGoals += scoresA[i]
+		outcome.LoserGoals += scoresB[i]
+
+		if scoresA[i] > scoresB[i] {
+			outcome.WinnerSets++
+		} else if scoresB[i] > scoresA[i] {
+			outcome.LoserSets++
+		}
+	}
+
+	return outcome, nil
+}
+
+func achievementResult(m *Match, topRatedUserId uint) (achievement.MatchResult, error) {
+	scoresA, scoresB, err := m.scores()
+	if err != nil {
+		return achievement.MatchResult{}, err
+	}
+
+	result := achievement.MatchResult{
+		MatchId:        m.Id,
+		ClubId:         m.ClubId,
+		GameId:         m.GameId,
+		TeamA:          m.TeamA,
+		TeamB:          m.TeamB,
+		ScoresA:        scoresA,
+		ScoresB:        scoresB,
+		TopRatedUserId: topRatedUserId,
+	}
+
+	switch m.Result {
+	case TeamAWins:
+		result.WinnerIds = m.TeamA
+	case TeamBWins:
+		result.WinnerIds = m.TeamB
+	case Placed:
+		// A free-for-all match is only won by a player placing first on their own.
+		for i, placement := range m.Placements {
+			if placement == 1 {
+				result.WinnerIds = append(result.WinnerIds, m.TeamA[i])
+			}
+		}
+
+		if len(result.WinnerIds) > 1 {
+			result.WinnerIds = nil
+		}
+	}
+
+	return result, nil
+}
+
+func ratingOutcome(m *Match) rating.Outcome {
+	winners, losers, winningTeamId, losingTeamId := m.Sides()
+
+	return rating.Outcome{
+		Draw:          m.Result == Draw,
+		WinnerIds:     winners,
+		LoserIds:      losers,
+		WinningTeamId: winningTeamId,
+		LosingTeamId:  losingTeamId,
+		Placements:    m.PlacementsByUserId(),
+	}
+}
+
+// placementsFromScores ranks final scores, highest first, where equal scores share a place
+// and the place after them is skipped.
+func placementsFromScores(scores []int) []int {
+	placements := make([]int, len(scores))
+	for i, score := range scores {
+		placements[i] = 1
+		for _, other := range scores {
+			if other > score {
+				placements[i]++
+			}
+		}
+	}
+
+	return placements
+}
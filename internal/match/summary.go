@@ -0,0 +1,134 @@
+package match
+
+import (
+	"sort"
+)
+
+// comebackDeficit is how many goals a side has to be behind in a set for winning it to count as a comeback.
+const comebackDeficit = 3
+
+// Summary is what can be derived from the event log of a match.
+type Summary struct {
+	Players  []PlayerSummary `json:"players"`
+	Sets     []SetSummary    `json:"sets"`
+	Timeouts int             `json:"timeouts"`
+	Swaps    int             `json:"swaps"`
+}
+
+type PlayerSummary struct {
+	UserId          uint           `json:"userId"`
+	Goals           int            `json:"goals"`
+	OwnGoals        int            `json:"ownGoals"`
+	GoalsByPosition map[string]int `json:"goalsByPosition"`
+}
+
+type SetSummary struct {
+	Set    int `json:"set"`
+	ScoreA int `json:"scoreA"`
+	ScoreB int `json:"scoreB"`
+	// LargestDeficit is the most goals the winner of the set was behind at any point.
+	LargestDeficit int  `json:"largestDeficit"`
+	Comeback       bool `json:"comeback"`
+	// Shutout is a set won without the other side scoring.
+	Shutout bool `json:"shutout"`
+}
+
+// Summarize derives goals per player, comebacks and shutouts from the event log of a match.
+func Summarize(match *Match, events []Event) Summary {
+	summary := Summary{
+		Players: []PlayerSummary{},
+		Sets:    []SetSummary{},
+	}
+
+	players := map[uint]*PlayerSummary{}
+	for _, userId := range match.players() {
+		players[userId] = &PlayerSummary{
+			UserId:          userId,
+			GoalsByPosition: map[string]int{},
+		}
+	}
+
+	sets := map[int]*SetSummary{}
+	deficits := map[int][2]int{}
+
+	for _, event := range events {
+		switch event.Type {
+		case EventTimeout:
+			summary.Timeouts++
+			continue
+		case EventSwap:
+			summary.Swaps++
+			continue
+		}
+
+		set, ok := sets[event.Set]
+		if !ok {
+			set = &SetSummary{Set: event.Set}
+			sets[event.Set] = set
+		}
+
+		if event.Side == SideA {
+			set.ScoreA++
+		} else {
+			set.ScoreB++
+		}
+
+		// The largest deficit of each side is tracked, as the winner of the set is not known until it ends.
+		deficit := deficits[event.Set]
+		deficit[0] = max(deficit[0], set.ScoreB-set.ScoreA)
+		deficit[1] = max(deficit[1], set.ScoreA-set.ScoreB)
+		deficits[event.Set] = deficit
+
+		player, ok := players[event.UserId]
+		if !ok {
+			continue
+		}
+
+		scoredFor := SideB
+		if contains(match.TeamA, event.UserId) {
+			scoredFor = SideA
+		}
+
+		if event.Side != scoredFor {
+			player.OwnGoals++
+			continue
+		}
+
+		player.Goals++
+		if event.Position != "" {
+			player.GoalsByPosition[event.Position]++
+		}
+	}
+
+	for _, set := range sets {
+		switch {
+		case set.ScoreA > set.ScoreB:
+			set.LargestDeficit = deficits[set.Set][0]
+			set.Shutout = set.ScoreB == 0
+		case set.ScoreB > set.ScoreA:
+			set.LargestDeficit = deficits[set.Set][1]
+			set.Shutout = set.ScoreA == 0
+		}
+
+		set.Comeback = set.LargestDeficit >= comebackDeficit
+		summary.Sets = append(summary.Sets, *set)
+	}
+
+	sort.Slice(summary.Sets, func(i, j int) bool {
+		return summary.Sets[i].Set < summary.Sets[j].Set
+	})
+
+	for _, userId := range match.players() {
+		summary.Players = append(summary.Players, *players[userId])
+	}
+
+	return summary
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
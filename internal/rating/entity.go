@@ -23,12 +23,49 @@ const (
 type Rating struct {
 	Id uint `gorm:"primaryKey"`
 
-	UserId uint `gorm:"not null"`
-	GameId uint `gorm:"not null"`
+	// A user or team has a single row per game in a club.
+	UserId uint `gorm:"not null;uniqueIndex:idx_ratings_standing"`
+	TeamId uint `gorm:"index;uniqueIndex:idx_ratings_standing"`
+	ClubId uint `gorm:"index;uniqueIndex:idx_ratings_standing"`
+	GameId uint `gorm:"not null;uniqueIndex:idx_ratings_standing"`
 
-	Value      float64 `gorm:"default:1000.0"`
+	Value      float64 `gorm:"default:0"`
 	Deviation  float64
 	Volatility float64 `gorm:"default:0.06"`
 
 	CreatedAt time.Time
 }
+
+// Outcome is the result of a single rated match.
+// On a draw the winning and losing sides are simply the two sides of the match.
+// A free-for-all match has placements instead of sides, where a lower placement is better.
+type Outcome struct {
+	Draw          bool
+	WinnerIds     []uint
+	LoserIds      []uint
+	WinningTeamId uint
+	LosingTeamId  uint
+	Placements    map[uint]int
+}
+
+// UserIds returns everyone the outcome concerns.
+func (o Outcome) UserIds() []uint {
+	userIds := append(append([]uint{}, o.WinnerIds...), o.LoserIds...)
+	for userId := range o.Placements {
+		userIds = append(userIds, userId)
+	}
+
+	return userIds
+}
+
+// TeamIds returns the teams the outcome concerns.
+func (o Outcome) TeamIds() []uint {
+	var teamIds []uint
+	for _, teamId := range []uint{o.WinningTeamId, o.LosingTeamId} {
+		if teamId != 0 {
+			teamIds = append(teamIds, teamId)
+		}
+	}
+
+	return teamIds
+}
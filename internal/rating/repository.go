@@ -2,16 +2,24 @@ package rating
 
 import (
 	"context"
+	"matchlog/pkg/database"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// statisticsJoin matches ratings with the statistics of the same user or team, which count the matches played.
+const statisticsJoin = "JOIN statistics ON statistics.club_id = ratings.club_id AND statistics.game_id = ratings.game_id " +
+	"AND statistics.user_id = ratings.user_id AND statistics.team_id = ratings.team_id"
+
 type Repository interface {
-	GetRatingByUserId(ctx context.Context, userId uint) (*Rating, error)
-	GetRatingsByUserIds(ctx context.Context, userIds []uint) ([]Rating, error)
-	GetTopXAmongUserIdsByRating(ctx context.Context, topX int, userIds []uint) (topXUserIds []uint, ratings []int, err error)
-	CreateRating(ctx context.Context, rating Rating) error
-	UpdateRating(ctx context.Context, ratings Rating) error
+	GetAllRatingsByUserId(ctx context.Context, userId uint) ([]Rating, error)
+	GetRatingsByUserIds(ctx context.Context, clubId, gameId uint, userIds []uint) ([]Rating, error)
+	GetRatingsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]Rating, error)
+	GetRatingsForUpdate(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) ([]Rating, error)
+	GetTopXAmongUserIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint) (topXUserIds []uint, ratings []float64, err error)
+	GetTopXAmongTeamIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, teamIds []uint) (topXTeamIds []uint, ratings []float64, err error)
+	CreateRatings(ctx context.Context, ratings []Rating) error
 	UpdateRatings(ctx context.Context, ratings []Rating) error
 }
 
@@ -23,23 +31,51 @@ func NewRepository(db *gorm.DB) Repository {
 	return &RepositoryImpl{db: db}
 }
 
-func (r *RepositoryImpl) GetRatingByUserId(ctx context.Context, userId uint) (*Rating, error) {
-	var rating Rating
-
-	result := r.db.WithContext(ctx).
+func (r *RepositoryImpl) GetAllRatingsByUserId(ctx context.Context, userId uint) ([]Rating, error) {
+	var ratings []Rating
+	result := database.Conn(ctx, r.db).
 		Where("user_id = ?", userId).
-		First(&rating)
+		Find(&ratings)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
-	return &rating, nil
+	return ratings, nil
+}
+
+func (r *RepositoryImpl) GetRatingsByUserIds(ctx context.Context, clubId, gameId uint, userIds []uint) ([]Rating, error) {
+	var ratings []Rating
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND user_id IN ?", clubId, gameId, userIds).
+		Find(&ratings)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return ratings, nil
+}
+
+func (r *RepositoryImpl) GetRatingsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]Rating, error) {
+	var ratings []Rating
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND team_id IN ?", clubId, gameId, teamIds).
+		Find(&ratings)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return ratings, nil
 }
 
-func (r *RepositoryImpl) GetRatingsByUserIds(ctx context.Context, userIds []uint) ([]Rating, error) {
+// GetRatingsForUpdate locks the ratings of the users and teams until the surrounding transaction ends.
+// Rows are locked in order of id, so transactions locking some of the same rows can not deadlock on each other.
+func (r *RepositoryImpl) GetRatingsForUpdate(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) ([]Rating, error) {
 	var ratings []Rating
-	result := r.db.WithContext(ctx).
-		Where("user_id IN ?", userIds).
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("club_id = ? AND game_id = ?", clubId, gameId).
+		Where(r.db.Where("team_id = 0 AND user_id IN ?", userIds).Or("team_id IN ?", teamIds)).
+		Order("id asc").
 		Find(&ratings)
 	if result.Error != nil {
 		return nil, result.Error
@@ -48,27 +84,62 @@ func (r *RepositoryImpl) GetRatingsByUserIds(ctx context.Context, userIds []uint
 	return ratings, nil
 }
 
-func (r *RepositoryImpl) GetTopXAmongUserIdsByRating(ctx context.Context, topX int, userIds []uint) ([]uint, []int, error) {
-	var topXUserIds []uint
-	var ratings []int
+// GetTopXAmongUserIdsByRating ranks the users that played at least minGames matches by rating.
+// Ratings do not count matches, so they are joined with the statistics of the same user.
+func (r *RepositoryImpl) GetTopXAmongUserIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint) ([]uint, []float64, error) {
+	var ratings []Rating
 
-	result := r.db.WithContext(ctx).
-		Model(&Rating{}).
-		Order("rating desc").
+	result := database.Conn(ctx, r.db).
+		Joins(statisticsJoin).
+		Where("ratings.club_id = ? AND ratings.game_id = ? AND ratings.user_id IN ?", clubId, gameId, userIds).
+		Where("statistics.wins + statistics.draws + statistics.losses >= ?", minGames).
+		Order("ratings.value desc, ratings.user_id asc").
 		Limit(topX).
-		Pluck("user_id", &topXUserIds).
-		Pluck("value", &ratings).
-		Where("user_id IN ?", userIds)
+		Find(&ratings)
 	if result.Error != nil {
 		return nil, nil, result.Error
 	}
 
-	return topXUserIds, ratings, nil
+	topXUserIds := make([]uint, len(ratings))
+	values := make([]float64, len(ratings))
+	for i, rating := range ratings {
+		topXUserIds[i] = rating.UserId
+		values[i] = rating.Value
+	}
+
+	return topXUserIds, values, nil
 }
 
-func (r *RepositoryImpl) CreateRating(ctx context.Context, rating Rating) error {
-	result := r.db.WithContext(ctx).
-		Create(&rating)
+// GetTopXAmongTeamIdsByRating ranks the teams that played at least minGames matches by rating.
+func (r *RepositoryImpl) GetTopXAmongTeamIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, teamIds []uint) ([]uint, []float64, error) {
+	var ratings []Rating
+
+	result := database.Conn(ctx, r.db).
+		Joins(statisticsJoin).
+		Where("ratings.club_id = ? AND ratings.game_id = ? AND ratings.team_id IN ?", clubId, gameId, teamIds).
+		Where("statistics.wins + statistics.draws + statistics.losses >= ?", minGames).
+		Order("ratings.value desc, ratings.team_id asc").
+		Limit(topX).
+		Find(&ratings)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	topXTeamIds := make([]uint, len(ratings))
+	values := make([]float64, len(ratings))
+	for i, rating := range ratings {
+		topXTeamIds[i] = rating.TeamId
+		values[i] = rating.Value
+	}
+
+	return topXTeamIds, values, nil
+}
+
+// CreateRatings skips ratings created in the meantime by a match confirmed at the same time.
+func (r *RepositoryImpl) CreateRatings(ctx context.Context, ratings []Rating) error {
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&ratings)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -77,10 +148,11 @@ func (r *RepositoryImpl) CreateRating(ctx context.Context, rating Rating) error
 }
 
 func (r *RepositoryImpl) UpdateRatings(ctx context.Context, ratings []Rating) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
 		for _, rating := range ratings {
 			result := tx.WithContext(ctx).
 				Model(&rating).
+				Select("*").
 				Updates(rating)
 			if result.Error != nil {
 				return result.Error
@@ -89,14 +161,3 @@ func (r *RepositoryImpl) UpdateRatings(ctx context.Context, ratings []Rating) er
 		return nil
 	})
 }
-
-func (r *RepositoryImpl) UpdateRating(ctx context.Context, rating Rating) error {
-	result := r.db.WithContext(ctx).
-		Model(&rating).
-		Updates(rating)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	return nil
-}
@@ -8,10 +8,13 @@ import (
 )
 
 type Service interface {
-	GetTopXAmongUserIdsByRating(ctx context.Context, topX int, userIds []uint) (topXUserIds []uint, ratings []int, err error)
-	CreateRating(ctx context.Context, userId uint) error
-	UpdateRatings(ctx context.Context, draw bool, winningUserIds, losingUserIds []uint) error
+	GetTopXAmongUserIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint) (topXUserIds []uint, ratings []float64, err error)
+	GetTopXAmongTeamIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, teamIds []uint) (topXTeamIds []uint, ratings []float64, err error)
+	GetRatingsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]Rating, error)
+	EnsureRatings(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) error
+	ApplyOutcome(ctx context.Context, clubId, gameId uint, outcome Outcome) error
 	TransferRatings(ctx context.Context, fromUserId, toUserId uint) error
+	RecalculateRatings(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint, outcomes []Outcome) error
 }
 
 type ServiceImpl struct {
@@ -24,8 +27,8 @@ func NewService(repo Repository) Service {
 	}
 }
 
-func (s *ServiceImpl) GetTopXAmongUserIdsByRating(ctx context.Context, topX int, userIds []uint) ([]uint, []int, error) {
-	userIds, ratings, err := s.repo.GetTopXAmongUserIdsByRating(ctx, topX, userIds)
+func (s *ServiceImpl) GetTopXAmongUserIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint) ([]uint, []float64, error) {
+	userIds, ratings, err := s.repo.GetTopXAmongUserIdsByRating(ctx, clubId, gameId, topX, minGames, userIds)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "failed to get top %d user ids by rating", topX)
 	}
@@ -33,32 +36,288 @@ func (s *ServiceImpl) GetTopXAmongUserIdsByRating(ctx context.Context, topX int,
 	return userIds, ratings, nil
 }
 
-func (s *ServiceImpl) CreateRating(ctx context.Context, userId uint) error {
-	rating := Rating{
-		UserId:     userId,
-		Value:      startRating,
-		Deviation:  maxDeviation,
-		Volatility: startVolatility,
+func (s *ServiceImpl) GetTopXAmongTeamIdsByRating(ctx context.Context, clubId, gameId uint, topX, minGames int, teamIds []uint) ([]uint, []float64, error) {
+	teamIds, ratings, err := s.repo.GetTopXAmongTeamIdsByRating(ctx, clubId, gameId, topX, minGames, teamIds)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get top %d team ids by rating", topX)
+	}
+
+	return teamIds, ratings, nil
+}
+
+func (s *ServiceImpl) GetRatingsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]Rating, error) {
+	ratings, err := s.repo.GetRatingsByTeamIds(ctx, clubId, gameId, teamIds)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get ratings for teams %v", teamIds)
+	}
+
+	return ratings, nil
+}
+
+// EnsureRatings creates starting ratings for the users and teams that have not played the game in the club before.
+func (s *ServiceImpl) EnsureRatings(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) error {
+	userRatings, err := s.repo.GetRatingsByUserIds(ctx, clubId, gameId, userIds)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ratings for users %v", userIds)
+	}
+
+	teamRatings, err := s.repo.GetRatingsByTeamIds(ctx, clubId, gameId, teamIds)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ratings for teams %v", teamIds)
+	}
+
+	hasUser := make(map[uint]bool, len(userRatings))
+	for _, rating := range userRatings {
+		hasUser[rating.UserId] = true
+	}
+
+	hasTeam := make(map[uint]bool, len(teamRatings))
+	for _, rating := range teamRatings {
+		hasTeam[rating.TeamId] = true
 	}
 
-	if err := s.repo.CreateRating(ctx, rating); err != nil {
-		return errors.Wrap(err, "failed to create rating")
+	var missing []Rating
+	for _, userId := range userIds {
+		if !hasUser[userId] {
+			hasUser[userId] = true
+			missing = append(missing, resetRating(Rating{UserId: userId, ClubId: clubId, GameId: gameId}))
+		}
+	}
+
+	for _, teamId := range teamIds {
+		if !hasTeam[teamId] {
+			hasTeam[teamId] = true
+			missing = append(missing, resetRating(Rating{TeamId: teamId, ClubId: clubId, GameId: gameId}))
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := s.repo.CreateRatings(ctx, missing); err != nil {
+		return errors.Wrap(err, "failed to create ratings")
 	}
 
 	return nil
 }
 
-func (s *ServiceImpl) UpdateRatings(ctx context.Context, draw bool, winningUserIds, losingUserIds []uint) error {
-	var updatedRatings []Rating
+// ApplyOutcome rates the players and teams of a single match.
+// The ratings are locked while they are changed, so matches confirmed at the same time do not overwrite each other.
+func (s *ServiceImpl) ApplyOutcome(ctx context.Context, clubId, gameId uint, outcome Outcome) error {
+	userRatings, teamRatings, err := s.getRatingsForUpdate(ctx, clubId, gameId, outcome.UserIds(), outcome.TeamIds())
+	if err != nil {
+		return err
+	}
 
-	winnerRatings, err := s.repo.GetRatingsByUserIds(ctx, winningUserIds)
+	ratingsByUserId := make(map[uint]Rating, len(userRatings))
+	for _, rating := range userRatings {
+		ratingsByUserId[rating.UserId] = rating
+	}
+
+	ratingsByTeamId := make(map[uint]Rating, len(teamRatings))
+	for _, rating := range teamRatings {
+		ratingsByTeamId[rating.TeamId] = rating
+	}
+
+	updatedRatings := s.applyOutcome(ratingsByUserId, ratingsByTeamId, outcome)
+
+	if err := s.repo.UpdateRatings(ctx, updatedRatings); err != nil {
+		return errors.Wrap(err, "failed to update ratings")
+	}
+
+	return nil
+}
+
+// getRatingsForUpdate locks the ratings of the users and teams, and returns those of the users apart from those of the teams.
+func (s *ServiceImpl) getRatingsForUpdate(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) ([]Rating, []Rating, error) {
+	ratings, err := s.repo.GetRatingsForUpdate(ctx, clubId, gameId, userIds, teamIds)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get ratings for users %v and teams %v", userIds, teamIds)
+	}
+
+	var userRatings, teamRatings []Rating
+	for _, rating := range ratings {
+		if rating.TeamId != 0 {
+			teamRatings = append(teamRatings, rating)
+		} else {
+			userRatings = append(userRatings, rating)
+		}
+	}
+
+	return userRatings, teamRatings, nil
+}
+
+// TransferRatings swaps the ratings of two users in every club and game.
+func (s *ServiceImpl) TransferRatings(ctx context.Context, fromUserId, toUserId uint) error {
+	fromRatings, err := s.repo.GetAllRatingsByUserId(ctx, fromUserId)
 	if err != nil {
-		return errors.Wrap(err, "failed to get winning users")
+		return errors.Wrap(err, "failed to get from user ratings")
 	}
 
-	loserRatings, err := s.repo.GetRatingsByUserIds(ctx, losingUserIds)
+	toRatings, err := s.repo.GetAllRatingsByUserId(ctx, toUserId)
 	if err != nil {
-		return errors.Wrap(err, "failed to get losing users")
+		return errors.Wrap(err, "failed to get to user ratings")
+	}
+
+	transferedRatings := make([]Rating, 0, len(fromRatings)+len(toRatings))
+	for _, rating := range fromRatings {
+		rating.UserId = toUserId
+		transferedRatings = append(transferedRatings, rating)
+	}
+
+	for _, rating := range toRatings {
+		rating.UserId = fromUserId
+		transferedRatings = append(transferedRatings, rating)
+	}
+
+	if err := s.repo.UpdateRatings(ctx, transferedRatings); err != nil {
+		return errors.Wrap(err, "failed to update ratings")
+	}
+
+	return nil
+}
+
+// RecalculateRatings resets the ratings of the given users and teams and replays the outcomes in order.
+func (s *ServiceImpl) RecalculateRatings(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint, outcomes []Outcome) error {
+	userRatings, teamRatings, err := s.getRatingsForUpdate(ctx, clubId, gameId, userIds, teamIds)
+	if err != nil {
+		return err
+	}
+
+	ratingsByUserId := make(map[uint]Rating, len(userRatings))
+	for _, rating := range userRatings {
+		ratingsByUserId[rating.UserId] = resetRating(rating)
+	}
+
+	ratingsByTeamId := make(map[uint]Rating, len(teamRatings))
+	for _, rating := range teamRatings {
+		ratingsByTeamId[rating.TeamId] = resetRating(rating)
+	}
+
+	for _, outcome := range outcomes {
+		s.applyOutcome(ratingsByUserId, ratingsByTeamId, outcome)
+	}
+
+	updatedRatings := make([]Rating, 0, len(ratingsByUserId)+len(ratingsByTeamId))
+	for _, rating := range ratingsByUserId {
+		updatedRatings = append(updatedRatings, rating)
+	}
+
+	for _, rating := range ratingsByTeamId {
+		updatedRatings = append(updatedRatings, rating)
+	}
+
+	if err := s.repo.UpdateRatings(ctx, updatedRatings); err != nil {
+		return errors.Wrap(err, "failed to update recalculated ratings")
+	}
+
+	return nil
+}
+
+// applyOutcome rates a single match, writes the new ratings back into the given maps and returns them.
+func (s *ServiceImpl) applyOutcome(ratingsByUserId, ratingsByTeamId map[uint]Rating, outcome Outcome) []Rating {
+	if len(outcome.Placements) > 0 {
+		updatedRatings := s.ratePlacements(ratingsByUserId, outcome.Placements)
+		for _, rating := range updatedRatings {
+			ratingsByUserId[rating.UserId] = rating
+		}
+
+		return updatedRatings
+	}
+
+	winnerRatings := s.pick(ratingsByUserId, outcome.WinnerIds)
+	loserRatings := s.pick(ratingsByUserId, outcome.LoserIds)
+
+	updatedRatings := s.rateMatch(outcome.Draw, winnerRatings, loserRatings)
+	for _, rating := range updatedRatings {
+		ratingsByUserId[rating.UserId] = rating
+	}
+
+	// Teams are only rated against other teams, a pair beating a single player says little about the pair.
+	if outcome.WinningTeamId == 0 || outcome.LosingTeamId == 0 {
+		return updatedRatings
+	}
+
+	winnerRatings = s.pick(ratingsByTeamId, []uint{outcome.WinningTeamId})
+	loserRatings = s.pick(ratingsByTeamId, []uint{outcome.LosingTeamId})
+
+	for _, rating := range s.rateMatch(outcome.Draw, winnerRatings, loserRatings) {
+		ratingsByTeamId[rating.TeamId] = rating
+		updatedRatings = append(updatedRatings, rating)
+	}
+
+	return updatedRatings
+}
+
+// ratePlacements rates a free-for-all match as if every player had played every other player,
+// winning against anyone placed below them and drawing with anyone sharing their placement.
+func (s *ServiceImpl) ratePlacements(ratingsByUserId map[uint]Rating, placements map[uint]int) []Rating {
+	var updatedRatings []Rating
+
+	for userId, placement := range placements {
+		rating, ok := ratingsByUserId[userId]
+		if !ok {
+			continue
+		}
+
+		var matchResults []MatchResult
+		for opponentId, opponentPlacement := range placements {
+			opponentRating, ok := ratingsByUserId[opponentId]
+			if opponentId == userId || !ok {
+				continue
+			}
+
+			result := resultMultiplierDraw
+			switch {
+			case placement < opponentPlacement:
+				result = resultMultiplierWin
+			case placement > opponentPlacement:
+				result = resultMultiplierLoss
+			}
+
+			matchResults = append(matchResults, MatchResult{
+				OpponentRating:    opponentRating.Value,
+				OpponentDeviation: opponentRating.Deviation,
+				Result:            result,
+			})
+		}
+
+		if len(matchResults) == 0 {
+			continue
+		}
+
+		updatedRatings = append(updatedRatings, ApplyActiveRatingPeriod(rating, matchResults))
+	}
+
+	return updatedRatings
+}
+
+func (s *ServiceImpl) pick(ratings map[uint]Rating, ids []uint) []Rating {
+	picked := make([]Rating, 0, len(ids))
+	for _, id := range ids {
+		if rating, ok := ratings[id]; ok {
+			picked = append(picked, rating)
+		}
+	}
+
+	return picked
+}
+
+func resetRating(rating Rating) Rating {
+	rating.Value = startRating
+	rating.Deviation = maxDeviation
+	rating.Volatility = startVolatility
+
+	return rating
+}
+
+func (s *ServiceImpl) rateMatch(draw bool, winnerRatings, loserRatings []Rating) []Rating {
+	var updatedRatings []Rating
+
+	// Without anyone on the other side there is no one to be rated against.
+	if len(winnerRatings) == 0 || len(loserRatings) == 0 {
+		return updatedRatings
 	}
 
 	winnerAverageRating, winnerAverageDeviation := s.getAverageRatingAndDeviation(winnerRatings)
@@ -102,33 +361,7 @@ func (s *ServiceImpl) UpdateRatings(ctx context.Context, draw bool, winningUserI
 		updatedRatings = append(updatedRatings, updatedRating)
 	}
 
-	if err := s.repo.UpdateRatings(ctx, updatedRatings); err != nil {
-		return errors.Wrap(err, "failed to update ratings")
-	}
-
-	return nil
-}
-
-func (s *ServiceImpl) TransferRatings(ctx context.Context, fromUserId, toUserId uint) error {
-	fromUserRating, err := s.repo.GetRatingByUserId(ctx, fromUserId)
-	if err != nil {
-		return errors.Wrap(err, "failed to get from user rating")
-	}
-
-	toUserRating, err := s.repo.GetRatingByUserId(ctx, toUserId)
-	if err != nil {
-		return errors.Wrap(err, "failed to get to user rating")
-	}
-
-	fromUserRating.UserId = toUserId
-	toUserRating.UserId = fromUserId
-
-	transferedRatings := []Rating{*fromUserRating, *toUserRating}
-	if err := s.repo.UpdateRatings(ctx, transferedRatings); err != nil {
-		return errors.Wrap(err, "failed to update ratings")
-	}
-
-	return nil
+	return updatedRatings
 }
 
 func (s *ServiceImpl) getAverageRatingAndDeviation(ratings []Rating) (float64, float64) {
@@ -101,22 +101,5 @@ func (h *Handlers) Signup(c echo.Context) error {
 		return echo.ErrBadRequest
 	}
 
-	exists, u, err := h.userService.GetUserByEmail(ctx, req.Email)
-	if err != nil {
-		h.logger.Error("failed to get user by email",
-			"error", err)
-		return echo.ErrInternalServerError
-	}
-
-	if !exists {
-		return echo.ErrInternalServerError
-	}
-
-	if err = h.statisticService.CreateStatistic(ctx, u.Id); err != nil {
-		h.logger.Error("failed to create statistic",
-			"error", err)
-		return echo.ErrInternalServerError
-	}
-
 	return c.NoContent(http.StatusCreated)
 }
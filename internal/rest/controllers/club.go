@@ -1,12 +1,16 @@
 package controllers
 
 import (
+	"context"
 	"matchlog/internal/club"
 	"matchlog/internal/rest/handlers"
 	"matchlog/internal/rest/helpers"
+	"matchlog/internal/user"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
 func (h *Handlers) CreateClub(c handlers.AuthenticatedContext) error {
@@ -43,6 +47,10 @@ func (h *Handlers) DeleteClub(c handlers.AuthenticatedContext) error {
 
 	ctx := c.Request().Context()
 
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionDeleteClub); err != nil {
+		return err
+	}
+
 	if err := h.clubService.DeleteClub(ctx, req.ClubId); err != nil {
 		h.logger.Error("failed to delete Club",
 			"error", err)
@@ -54,8 +62,9 @@ func (h *Handlers) DeleteClub(c handlers.AuthenticatedContext) error {
 
 func (h *Handlers) UpdateClub(c handlers.AuthenticatedContext) error {
 	type request struct {
-		ClubId uint   `json:"clubId" validate:"required,gt=0"`
-		Name   string `json:"name" validate:"required"`
+		ClubId       uint   `json:"clubId" validate:"required,gt=0"`
+		Name         string `json:"name" validate:"required"`
+		Discoverable *bool  `json:"discoverable"`
 	}
 
 	req, err := helpers.Bind[request](c)
@@ -65,20 +74,32 @@ func (h *Handlers) UpdateClub(c handlers.AuthenticatedContext) error {
 
 	ctx := c.Request().Context()
 
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionUpdateClub); err != nil {
+		return err
+	}
+
 	if err := h.clubService.UpdateClub(ctx, req.ClubId, req.Name); err != nil {
 		h.logger.Error("failed to update Club",
 			"error", err)
 		return echo.ErrInternalServerError
 	}
 
+	if req.Discoverable != nil {
+		if err := h.clubService.SetDiscoverable(ctx, req.ClubId, *req.Discoverable); err != nil {
+			h.logger.Error("failed to set whether Club is discoverable",
+				"error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
 	return c.NoContent(http.StatusOK)
 }
 
 func (h *Handlers) UpdateUserRole(c handlers.AuthenticatedContext) error {
 	type request struct {
-		ClubId uint      `param:"clubId" validate:"required,gt=0"`
+		ClubId uint      `json:"clubId" validate:"required,gt=0"`
 		UserId uint      `param:"userId" validate:"required,gt=0"`
-		Role   club.Role `json:"role" validate:"required"`
+		Role   club.Role `json:"role" validate:"required,oneof=admin manager member"`
 	}
 
 	req, err := helpers.Bind[request](c)
@@ -88,7 +109,15 @@ func (h *Handlers) UpdateUserRole(c handlers.AuthenticatedContext) error {
 
 	ctx := c.Request().Context()
 
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionUpdateRole); err != nil {
+		return err
+	}
+
 	if err := h.clubService.UpdateUserRole(ctx, req.UserId, req.ClubId, req.Role); err != nil {
+		if errors.Is(err, club.ErrLastAdmin) {
+			return echo.NewHTTPError(http.StatusConflict, club.ErrLastAdmin.Error())
+		}
+
 		h.logger.Error("failed to update user role",
 			"error", err)
 		return echo.ErrInternalServerError
@@ -120,6 +149,10 @@ func (h *Handlers) GetUsersInClub(c handlers.AuthenticatedContext) error {
 		return echo.ErrBadRequest
 	}
 
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
 	userIds, err := h.clubService.GetUserIdsInClub(ctx, req.ClubId)
 	if err != nil {
 		h.logger.Error("failed to get userIds in Club",
@@ -152,9 +185,10 @@ func (h *Handlers) GetUsersInClub(c handlers.AuthenticatedContext) error {
 
 func (h *Handlers) GetUserInvites(c handlers.AuthenticatedContext) error {
 	type responseClub struct {
-		Id     uint   `json:"id"`
-		ClubId uint   `json:"club_id"`
-		Name   string `json:"name"`
+		Id        uint       `json:"id"`
+		ClubId    uint       `json:"club_id"`
+		Name      string     `json:"name"`
+		ExpiresAt *time.Time `json:"expires_at"`
 	}
 
 	type response struct {
@@ -182,12 +216,18 @@ func (h *Handlers) GetUserInvites(c handlers.AuthenticatedContext) error {
 		return echo.ErrInternalServerError
 	}
 
-	invites := make([]responseClub, len(clubs))
-	for i, c := range clubs {
+	clubNames := make(map[uint]string, len(clubs))
+	for _, c := range clubs {
+		clubNames[c.Id] = c.Name
+	}
+
+	invites := make([]responseClub, len(clubUsers))
+	for i, clubUser := range clubUsers {
 		invites[i] = responseClub{
-			Id:     clubUsers[i].Id,
-			ClubId: c.Id,
-			Name:   c.Name,
+			Id:        clubUser.Id,
+			ClubId:    clubUser.ClubId,
+			Name:      clubNames[clubUser.ClubId],
+			ExpiresAt: clubUser.ExpiresAt,
 		}
 	}
 
@@ -198,10 +238,114 @@ func (h *Handlers) GetUserInvites(c handlers.AuthenticatedContext) error {
 	return c.JSON(http.StatusOK, resp)
 }
 
+func (h *Handlers) GetInvitesInClub(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint `query:"clubId" validate:"required,gt=0"`
+	}
+
+	type responseInvite struct {
+		Id        uint       `json:"id"`
+		UserId    uint       `json:"user_id"`
+		Name      string     `json:"name"`
+		Email     string     `json:"email"`
+		Status    string     `json:"status"`
+		InvitedBy uint       `json:"invited_by"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		CreatedAt time.Time  `json:"created_at"`
+	}
+
+	type response struct {
+		Invites []responseInvite `json:"invites"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionInvite); err != nil {
+		return err
+	}
+
+	invites, err := h.clubService.GetInvitesInClub(ctx, req.ClubId)
+	if err != nil {
+		h.logger.Error("failed to get invites in Club",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	userIds := make([]uint, len(invites))
+	for i, invite := range invites {
+		userIds[i] = invite.UserId
+	}
+
+	users, err := h.userService.GetUsers(ctx, userIds)
+	if err != nil {
+		h.logger.Error("failed to get users",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	usersById := make(map[uint]*user.User, len(users))
+	for _, u := range users {
+		usersById[u.Id] = u
+	}
+
+	now := time.Now()
+	respInvites := make([]responseInvite, len(invites))
+	for i, invite := range invites {
+		respInvites[i] = responseInvite{
+			Id:        invite.Id,
+			UserId:    invite.UserId,
+			Status:    string(invite.CurrentStatus(now)),
+			InvitedBy: invite.InvitedBy,
+			ExpiresAt: invite.ExpiresAt,
+			CreatedAt: invite.CreatedAt,
+		}
+
+		if u, ok := usersById[invite.UserId]; ok {
+			respInvites[i].Name = u.Name
+			respInvites[i].Email = u.Email
+		}
+	}
+
+	resp := response{
+		Invites: respInvites,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) RevokeInvite(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId   uint `json:"clubId" validate:"required,gt=0"`
+		InviteId uint `param:"inviteId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionRevokeInvite); err != nil {
+		return err
+	}
+
+	if err := h.clubService.RevokeInvite(ctx, req.ClubId, req.InviteId); err != nil {
+		return h.inviteResponseError(err, "failed to revoke invite")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
 func (h *Handlers) InviteUsersToClub(c handlers.AuthenticatedContext) error {
 	type request struct {
-		ClubId uint     `json:"club_id"`
-		Emails []string `json:"emails"`
+		ClubId uint     `json:"club_id" validate:"required,gt=0"`
+		Emails []string `json:"emails" validate:"required"`
 	}
 
 	ctx := c.Request().Context()
@@ -211,6 +355,10 @@ func (h *Handlers) InviteUsersToClub(c handlers.AuthenticatedContext) error {
 		return echo.ErrBadRequest
 	}
 
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionInvite); err != nil {
+		return err
+	}
+
 	users, err := h.userService.GetUsersByEmails(ctx, req.Emails)
 	if err != nil {
 		h.logger.Error("failed to get users by email",
@@ -223,7 +371,7 @@ func (h *Handlers) InviteUsersToClub(c handlers.AuthenticatedContext) error {
 		userIds[i] = u.Id
 	}
 
-	if err := h.clubService.InviteToClub(ctx, userIds, req.ClubId); err != nil {
+	if err := h.clubService.InviteToClub(ctx, userIds, req.ClubId, c.Claims.UserId); err != nil {
 		h.logger.Error("failed to invite users to club",
 			"error", err)
 		return echo.ErrInternalServerError
@@ -231,3 +379,54 @@ func (h *Handlers) InviteUsersToClub(c handlers.AuthenticatedContext) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+// authorizeClub returns the HTTP error to respond with unless the user's role in the club allows the action.
+func (h *Handlers) authorizeClub(ctx context.Context, userId, clubId uint, action club.Action) error {
+	if _, err := h.clubService.Authorize(ctx, userId, clubId, action); err != nil {
+		if errors.Is(err, club.ErrForbidden) {
+			return echo.ErrForbidden
+		}
+
+		h.logger.Error("failed to authorize club action",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return nil
+}
+
+func (h *Handlers) inviteResponseError(err error, msg string) error {
+	switch {
+	case errors.Is(err, club.ErrNotFound):
+		return echo.ErrNotFound
+	case errors.Is(err, club.ErrInviteExpired):
+		return echo.NewHTTPError(http.StatusGone, club.ErrInviteExpired.Error())
+	case errors.Is(err, club.ErrInviteNotPending):
+		return echo.NewHTTPError(http.StatusConflict, club.ErrInviteNotPending.Error())
+	default:
+		h.logger.Error(msg,
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+}
+
+// viewableClubIds returns the club asked for if the user can view it. Without a club it returns the clubs
+// the user shares with every one of the others, so records across clubs only cover clubs the user is part of.
+func (h *Handlers) viewableClubIds(ctx context.Context, userId, clubId uint, others ...uint) ([]uint, error) {
+	if clubId != 0 {
+		if err := h.authorizeClub(ctx, userId, clubId, club.ActionView); err != nil {
+			return nil, err
+		}
+
+		return []uint{clubId}, nil
+	}
+
+	clubIds, err := h.clubService.GetSharedClubIds(ctx, append([]uint{userId}, others...))
+	if err != nil {
+		h.logger.Error("failed to get shared Clubs",
+			"error", err)
+		return nil, echo.ErrInternalServerError
+	}
+
+	return clubIds, nil
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/rest/handlers"
+	"matchlog/internal/rest/helpers"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handlers) AddGameToClub(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint          `json:"clubId" validate:"required,gt=0"`
+		Name   string        `json:"name" validate:"required"`
+		Type   game.GameType `json:"type" validate:"required,oneof=ffa team coop"`
+
+		PointsToWinSet int  `json:"pointsToWinSet" validate:"gte=0"`
+		WinByTwo       bool `json:"winByTwo"`
+		BestOf         int  `json:"bestOf" validate:"gte=0"`
+		AllowDraws     bool `json:"allowDraws"`
+		MaxScore       int  `json:"maxScore" validate:"gte=0"`
+	}
+
+	type response struct {
+		Id uint `json:"id"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionManageGames); err != nil {
+		return err
+	}
+
+	if req.MaxScore > 0 && req.MaxScore < req.PointsToWinSet {
+		return echo.ErrBadRequest
+	}
+
+	g := &game.Game{
+		Name: req.Name,
+		Type: req.Type,
+		Rules: game.Rules{
+			PointsToWinSet: req.PointsToWinSet,
+			WinByTwo:       req.WinByTwo,
+			BestOf:         req.BestOf,
+			AllowDraws:     req.AllowDraws,
+			MaxScore:       req.MaxScore,
+		},
+	}
+
+	if err := h.gameService.CreateGame(ctx, g); err != nil {
+		h.logger.Error("failed to create game",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	if err := h.clubService.AddGameToClub(ctx, g.Id, req.ClubId); err != nil {
+		h.logger.Error("failed to add game to Club",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	resp := response{
+		Id: g.Id,
+	}
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handlers) GetGamesInClub(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint `query:"clubId" validate:"required,gt=0"`
+	}
+
+	type responseGame struct {
+		Id             uint   `json:"id"`
+		Name           string `json:"name"`
+		Type           string `json:"type"`
+		PointsToWinSet int    `json:"pointsToWinSet"`
+		WinByTwo       bool   `json:"winByTwo"`
+		BestOf         int    `json:"bestOf"`
+		AllowDraws     bool   `json:"allowDraws"`
+		MaxScore       int    `json:"maxScore"`
+	}
+
+	type response struct {
+		Games []responseGame `json:"games"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	gameIds, err := h.clubService.GetGameIdsInClub(ctx, req.ClubId)
+	if err != nil {
+		h.logger.Error("failed to get gameIds in Club",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	respGames := []responseGame{}
+	if len(gameIds) > 0 {
+		games, err := h.gameService.GetGames(ctx, gameIds)
+		if err != nil {
+			h.logger.Error("failed to get games",
+				"error", err)
+			return echo.ErrInternalServerError
+		}
+
+		for _, g := range games {
+			respGames = append(respGames, responseGame{
+				Id:             g.Id,
+				Name:           g.Name,
+				Type:           string(g.Type),
+				PointsToWinSet: g.PointsToWinSet,
+				WinByTwo:       g.WinByTwo,
+				BestOf:         g.BestOf,
+				AllowDraws:     g.AllowDraws,
+				MaxScore:       g.MaxScore,
+			})
+		}
+	}
+
+	resp := response{
+		Games: respGames,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
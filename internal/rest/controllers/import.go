@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/importer"
+	"matchlog/internal/match"
+	"matchlog/internal/rest/handlers"
+	"matchlog/internal/rest/helpers"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// ImportMatches imports historical matches from an uploaded CSV or JSON file.
+// Only admins of the club can import matches.
+func (h *Handlers) ImportMatches(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint   `form:"clubId" validate:"required,gt=0"`
+		GameId uint   `form:"gameId" validate:"required,gt=0"`
+		Format string `form:"format" validate:"omitempty,oneof=csv json"`
+		DryRun bool   `form:"dryRun"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionImportMatches); err != nil {
+		return err
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	format := req.Format
+	if format == "" {
+		format = strings.ToLower(strings.TrimPrefix(filepath.Ext(fileHeader.Filename), "."))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		h.logger.Error("failed to open uploaded file",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+	defer file.Close()
+
+	records, err := importer.Parse(file, importer.Format(format))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result, err := h.importerService.ImportMatches(ctx, req.ClubId, req.GameId, c.Claims.UserId, records, req.DryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, importer.ErrInvalidFile),
+			errors.Is(err, importer.ErrUnknownPlayer),
+			errors.Is(err, game.ErrInvalidScores),
+			errors.Is(err, match.ErrInvalidEvents),
+			errors.Is(err, match.ErrInvalidSubmission),
+			errors.Is(err, match.ErrNotClubMember),
+			errors.Is(err, match.ErrGameNotInClub):
+			// The reason is passed on, so the file can be corrected.
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		default:
+			h.logger.Error("failed to import matches",
+				"error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	status := http.StatusCreated
+	if req.DryRun {
+		status = http.StatusOK
+	}
+
+	return c.JSON(status, result)
+}
@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"matchlog/internal/club"
+	"matchlog/internal/rest/handlers"
+	"matchlog/internal/rest/helpers"
+	"matchlog/internal/user"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+func (h *Handlers) SearchClubs(c handlers.AuthenticatedContext) error {
+	type request struct {
+		Query string `query:"query" validate:"max=100"`
+		Limit int    `query:"limit" default:"20" validate:"omitempty,gt=0,lte=50"`
+	}
+
+	type responseClub struct {
+		Id   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+
+	type response struct {
+		Clubs []responseClub `json:"clubs"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	clubs, err := h.clubService.SearchClubs(ctx, req.Query, req.Limit)
+	if err != nil {
+		h.logger.Error("failed to search Clubs",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	respClubs := make([]responseClub, len(clubs))
+	for i, c := range clubs {
+		respClubs[i] = responseClub{
+			Id:   c.Id,
+			Name: c.Name,
+		}
+	}
+
+	resp := response{
+		Clubs: respClubs,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) JoinWithInviteLink(c handlers.AuthenticatedContext) error {
+	type request struct {
+		Code string `json:"code" validate:"required,max=32"`
+	}
+
+	type response struct {
+		ClubId uint `json:"club_id"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	clubId, err := h.clubService.JoinWithInviteLink(ctx, c.Claims.UserId, req.Code)
+	if err != nil {
+		return h.joinResponseError(err, "failed to join Club with invite link")
+	}
+
+	resp := response{
+		ClubId: clubId,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) RequestToJoinClub(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId  uint   `param:"clubId" validate:"required,gt=0"`
+		Message string `json:"message" validate:"max=500"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.clubService.RequestToJoin(ctx, c.Claims.UserId, req.ClubId, req.Message); err != nil {
+		return h.joinResponseError(err, "failed to request to join Club")
+	}
+
+	return c.NoContent(http.StatusCreated)
+}
+
+type responseInviteLink struct {
+	Id        uint       `json:"id"`
+	Code      string     `json:"code"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	Usable    bool       `json:"usable"`
+	CreatedBy uint       `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func newResponseInviteLink(link club.InviteLink, now time.Time) responseInviteLink {
+	return responseInviteLink{
+		Id:        link.Id,
+		Code:      link.Code,
+		MaxUses:   link.MaxUses,
+		Uses:      link.Uses,
+		Usable:    link.Usable(now),
+		CreatedBy: link.CreatedBy,
+		ExpiresAt: link.ExpiresAt,
+		RevokedAt: link.RevokedAt,
+		CreatedAt: link.CreatedAt,
+	}
+}
+
+func (h *Handlers) CreateInviteLink(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId    uint       `json:"clubId" validate:"required,gt=0"`
+		MaxUses   int        `json:"maxUses" validate:"gte=0"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionManageInviteLinks); err != nil {
+		return err
+	}
+
+	link, err := h.clubService.CreateInviteLink(ctx, req.ClubId, c.Claims.UserId, req.MaxUses, req.ExpiresAt)
+	if err != nil {
+		return h.joinResponseError(err, "failed to create invite link")
+	}
+
+	return c.JSON(http.StatusCreated, newResponseInviteLink(*link, time.Now()))
+}
+
+func (h *Handlers) GetInviteLinks(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint `query:"clubId" validate:"required,gt=0"`
+	}
+
+	type response struct {
+		Links []responseInviteLink `json:"links"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionManageInviteLinks); err != nil {
+		return err
+	}
+
+	links, err := h.clubService.GetInviteLinksInClub(ctx, req.ClubId)
+	if err != nil {
+		h.logger.Error("failed to get invite links",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	now := time.Now()
+	respLinks := make([]responseInviteLink, len(links))
+	for i, link := range links {
+		respLinks[i] = newResponseInviteLink(link, now)
+	}
+
+	resp := response{
+		Links: respLinks,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) RevokeInviteLink(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint `json:"clubId" validate:"required,gt=0"`
+		LinkId uint `param:"linkId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionManageInviteLinks); err != nil {
+		return err
+	}
+
+	if err := h.clubService.RevokeInviteLink(ctx, req.ClubId, req.LinkId); err != nil {
+		return h.joinResponseError(err, "failed to revoke invite link")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) GetJoinRequests(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint `query:"clubId" validate:"required,gt=0"`
+	}
+
+	type responseJoinRequest struct {
+		Id         uint      `json:"id"`
+		UserId     uint      `json:"user_id"`
+		Name       string    `json:"name"`
+		Email      string    `json:"email"`
+		Message    string    `json:"message"`
+		Status     string    `json:"status"`
+		ReviewedBy uint      `json:"reviewed_by,omitempty"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+
+	type response struct {
+		Requests []responseJoinRequest `json:"requests"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionReviewJoinRequests); err != nil {
+		return err
+	}
+
+	requests, err := h.clubService.GetJoinRequestsInClub(ctx, req.ClubId)
+	if err != nil {
+		h.logger.Error("failed to get join requests",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	userIds := make([]uint, len(requests))
+	for i, request := range requests {
+		userIds[i] = request.UserId
+	}
+
+	users, err := h.userService.GetUsers(ctx, userIds)
+	if err != nil {
+		h.logger.Error("failed to get users",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	usersById := make(map[uint]*user.User, len(users))
+	for _, u := range users {
+		usersById[u.Id] = u
+	}
+
+	respRequests := make([]responseJoinRequest, len(requests))
+	for i, request := range requests {
+		respRequests[i] = responseJoinRequest{
+			Id:         request.Id,
+			UserId:     request.UserId,
+			Message:    request.Message,
+			Status:     string(request.Status),
+			ReviewedBy: request.ReviewedBy,
+			CreatedAt:  request.CreatedAt,
+		}
+
+		if u, ok := usersById[request.UserId]; ok {
+			respRequests[i].Name = u.Name
+			respRequests[i].Email = u.Email
+		}
+	}
+
+	resp := response{
+		Requests: respRequests,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) ReviewJoinRequest(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId    uint  `json:"clubId" validate:"required,gt=0"`
+		RequestId uint  `param:"requestId" validate:"required,gt=0"`
+		Approve   *bool `json:"approve" validate:"required"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionReviewJoinRequests); err != nil {
+		return err
+	}
+
+	if err := h.clubService.ReviewJoinRequest(ctx, req.ClubId, req.RequestId, c.Claims.UserId, *req.Approve); err != nil {
+		return h.joinResponseError(err, "failed to review join request")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) joinResponseError(err error, msg string) error {
+	switch {
+	case errors.Is(err, club.ErrNotFound):
+		return echo.ErrNotFound
+	case errors.Is(err, club.ErrInvalidExpiry):
+		return echo.NewHTTPError(http.StatusBadRequest, club.ErrInvalidExpiry.Error())
+	case errors.Is(err, club.ErrLinkNotUsable):
+		return echo.NewHTTPError(http.StatusGone, club.ErrLinkNotUsable.Error())
+	case errors.Is(err, club.ErrAlreadyMember), errors.Is(err, club.ErrJoinRequestPending), errors.Is(err, club.ErrJoinRequestNotPending):
+		// The cause is one of the errors matched, without the ids it was wrapped with.
+		return echo.NewHTTPError(http.StatusConflict, errors.Cause(err).Error())
+	default:
+		h.logger.Error(msg,
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+}
@@ -1,19 +1,38 @@
 package controllers
 
 import (
+	"encoding/json"
+	"fmt"
+	"matchlog/internal/club"
 	"matchlog/internal/leaderboard"
 	"matchlog/internal/rest/handlers"
 	"matchlog/internal/rest/helpers"
+	"matchlog/internal/statistic"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
+// leaderboardStreamKeepAlive is how often a stream of leaderboards is kept alive when nothing changes.
+const leaderboardStreamKeepAlive = 30 * time.Second
+
 func (h *Handlers) GetLeaderboard(c handlers.AuthenticatedContext) error {
 	type request struct {
 		ClubId          uint                        `query:"clubId" validate:"required,gt=0"`
-		TopX            int                         `query:"topX" validate:"required,gt=0,lte=50"`
-		LeaderboardType leaderboard.LeaderboardType `query:"type" validate:"required,oneof=wins streak rating"`
+		GameId          uint                        `query:"gameId" validate:"required,gt=0"`
+		LeaderboardType leaderboard.LeaderboardType `query:"type" validate:"required,oneof=wins streak goals-for goals-against goal-difference sets-won sets-lost winrate longest-win-streak longest-loss-streak rating team-rating"`
+		MinGames        int                         `query:"minGames" validate:"gte=0"`
+		From            *time.Time                  `query:"from"`
+		To              *time.Time                  `query:"to"`
+		Period          statistic.Period            `query:"period" validate:"omitempty,oneof=week month quarter year"`
+		Since           leaderboard.Since           `query:"since" default:"week" validate:"omitempty,oneof=week match-day"`
+		Limit           int                         `query:"limit" default:"10" validate:"omitempty,gt=0,lte=50"`
+		Offset          int                         `query:"offset" validate:"gte=0"`
+		Cursor          string                      `query:"cursor" validate:"excluded_with=Offset"`
+		AroundUser      uint                        `query:"aroundUser" validate:"excluded_with=Offset Cursor"`
+		Neighbours      int                         `query:"neighbours" default:"2" validate:"omitempty,gt=0,lte=25"`
 	}
 
 	type response struct {
@@ -27,7 +46,32 @@ func (h *Handlers) GetLeaderboard(c handlers.AuthenticatedContext) error {
 		return echo.ErrBadRequest
 	}
 
-	leaderboard, err := h.leaderboardService.GetLeaderboard(ctx, req.ClubId, req.TopX, req.LeaderboardType)
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	from, to, err := resolveWindow(req.From, req.To, req.Period)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	page := leaderboard.Page{
+		Limit:        req.Limit,
+		Offset:       req.Offset,
+		Cursor:       req.Cursor,
+		AroundUserId: req.AroundUser,
+		Neighbours:   req.Neighbours,
+	}
+
+	lboard, err := h.leaderboardService.GetLeaderboard(ctx, req.ClubId, req.GameId, req.LeaderboardType, req.MinGames, from, to, req.Since, page)
+	if errors.Is(err, leaderboard.ErrWindowNotSupported) || errors.Is(err, leaderboard.ErrInvalidCursor) {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Cause(err).Error())
+	}
+
+	if errors.Is(err, leaderboard.ErrNotRanked) {
+		return echo.NewHTTPError(http.StatusNotFound, errors.Cause(err).Error())
+	}
+
 	if err != nil {
 		h.logger.Error("failed to get leaderboard",
 			"error", err)
@@ -35,8 +79,292 @@ func (h *Handlers) GetLeaderboard(c handlers.AuthenticatedContext) error {
 	}
 
 	resp := response{
-		Leaderboard: *leaderboard,
+		Leaderboard: *lboard,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetTopX serves the top of a leaderboard at the route it had before leaderboards were paged.
+//
+// Deprecated: use GetLeaderboard, which this answers as the first page of topX entries.
+func (h *Handlers) GetTopX(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId          uint                        `query:"clubId" validate:"required,gt=0"`
+		GameId          uint                        `query:"gameId" validate:"required,gt=0"`
+		TopX            int                         `param:"topX" validate:"required,gt=0,lte=50"`
+		LeaderboardType leaderboard.LeaderboardType `param:"leaderboardType" validate:"required,oneof=wins streak goals-for goals-against goal-difference sets-won sets-lost winrate longest-win-streak longest-loss-streak rating team-rating"`
+		MinGames        int                         `query:"minGames" validate:"gte=0"`
+		From            *time.Time                  `query:"from"`
+		To              *time.Time                  `query:"to"`
+		Period          statistic.Period            `query:"period" validate:"omitempty,oneof=week month quarter year"`
+	}
+
+	type response struct {
+		Leaderboard leaderboard.Leaderboard `json:"leaderboard"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	from, to, err := resolveWindow(req.From, req.To, req.Period)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	page := leaderboard.Page{
+		Limit: req.TopX,
+	}
+
+	lboard, err := h.leaderboardService.GetLeaderboard(ctx, req.ClubId, req.GameId, req.LeaderboardType, req.MinGames, from, to, leaderboard.SinceWeek, page)
+	if errors.Is(err, leaderboard.ErrWindowNotSupported) {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Cause(err).Error())
+	}
+
+	if err != nil {
+		h.logger.Error("failed to get leaderboard",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	resp := response{
+		Leaderboard: *lboard,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// resolveWindow turns either from and to, or a named period, into a window of time.
+// A period can not be combined with times, and neither means all time.
+func resolveWindow(from, to *time.Time, period statistic.Period) (*time.Time, *time.Time, error) {
+	if period == "" {
+		if from != nil && to != nil && !from.Before(*to) {
+			return nil, nil, errors.New("window ends before it starts")
+		}
+
+		return from, to, nil
+	}
+
+	if from != nil || to != nil {
+		return nil, nil, errors.New("window is given as both a period and times")
+	}
+
+	periodFrom, periodTo, err := period.Window(time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &periodFrom, &periodTo, nil
+}
+
+func (h *Handlers) GetGlobalLeaderboard(c handlers.AuthenticatedContext) error {
+	type request struct {
+		GameId          uint                        `query:"gameId" validate:"required,gt=0"`
+		LeaderboardType leaderboard.LeaderboardType `query:"type" validate:"required,oneof=wins goals-for goals-against goal-difference sets-won sets-lost winrate longest-win-streak longest-loss-streak"`
+		MinGames        int                         `query:"minGames" validate:"gte=0"`
+		Limit           int                         `query:"limit" default:"10" validate:"omitempty,gt=0,lte=50"`
+		Offset          int                         `query:"offset" validate:"gte=0"`
+		Cursor          string                      `query:"cursor" validate:"excluded_with=Offset"`
+		AroundUser      uint                        `query:"aroundUser" validate:"excluded_with=Offset Cursor"`
+		Neighbours      int                         `query:"neighbours" default:"2" validate:"omitempty,gt=0,lte=25"`
+	}
+
+	type response struct {
+		Leaderboard leaderboard.Leaderboard `json:"leaderboard"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	page := leaderboard.Page{
+		Limit:        req.Limit,
+		Offset:       req.Offset,
+		Cursor:       req.Cursor,
+		AroundUserId: req.AroundUser,
+		Neighbours:   req.Neighbours,
+	}
+
+	lboard, err := h.leaderboardService.GetGlobalLeaderboard(ctx, req.GameId, req.LeaderboardType, req.MinGames, page)
+	if errors.Is(err, leaderboard.ErrGlobalNotSupported) || errors.Is(err, leaderboard.ErrInvalidCursor) {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Cause(err).Error())
+	}
+
+	if errors.Is(err, leaderboard.ErrNotRanked) {
+		return echo.NewHTTPError(http.StatusNotFound, errors.Cause(err).Error())
+	}
+
+	if err != nil {
+		h.logger.Error("failed to get global leaderboard",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	resp := response{
+		Leaderboard: *lboard,
 	}
 
 	return c.JSON(http.StatusOK, resp)
 }
+
+func (h *Handlers) GetClubsLeaderboard(c handlers.AuthenticatedContext) error {
+	type request struct {
+		GameId          uint                        `query:"gameId" validate:"required,gt=0"`
+		LeaderboardType leaderboard.LeaderboardType `query:"type" validate:"required,oneof=wins streak goals-for goals-against goal-difference sets-won sets-lost winrate longest-win-streak longest-loss-streak"`
+		MinGames        int                         `query:"minGames" validate:"gte=0"`
+		Limit           int                         `query:"limit" default:"10" validate:"omitempty,gt=0,lte=50"`
+		Offset          int                         `query:"offset" validate:"gte=0"`
+		Cursor          string                      `query:"cursor" validate:"excluded_with=Offset"`
+	}
+
+	type response struct {
+		Leaderboard leaderboard.Leaderboard `json:"leaderboard"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	page := leaderboard.Page{
+		Limit:  req.Limit,
+		Offset: req.Offset,
+		Cursor: req.Cursor,
+	}
+
+	lboard, err := h.leaderboardService.GetClubsLeaderboard(ctx, req.GameId, req.LeaderboardType, req.MinGames, page)
+	if errors.Is(err, leaderboard.ErrGlobalNotSupported) || errors.Is(err, leaderboard.ErrInvalidCursor) {
+		return echo.NewHTTPError(http.StatusBadRequest, errors.Cause(err).Error())
+	}
+
+	if err != nil {
+		h.logger.Error("failed to get clubs leaderboard",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	resp := response{
+		Leaderboard: *lboard,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// StreamLeaderboard sends the leaderboard as Server-Sent Events, first as it is and then again every time the standings of the game in the club change.
+// Leaderboards of a window of time are not streamed, as the window would need to move along while streaming.
+func (h *Handlers) StreamLeaderboard(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId          uint                        `query:"clubId" validate:"required,gt=0"`
+		GameId          uint                        `query:"gameId" validate:"required,gt=0"`
+		LeaderboardType leaderboard.LeaderboardType `query:"type" validate:"required,oneof=wins streak goals-for goals-against goal-difference sets-won sets-lost winrate longest-win-streak longest-loss-streak rating team-rating"`
+		MinGames        int                         `query:"minGames" validate:"gte=0"`
+		Since           leaderboard.Since           `query:"since" default:"week" validate:"omitempty,oneof=week match-day"`
+		Limit           int                         `query:"limit" default:"10" validate:"omitempty,gt=0,lte=50"`
+		AroundUser      uint                        `query:"aroundUser"`
+		Neighbours      int                         `query:"neighbours" default:"2" validate:"omitempty,gt=0,lte=25"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	page := leaderboard.Page{
+		Limit:        req.Limit,
+		AroundUserId: req.AroundUser,
+		Neighbours:   req.Neighbours,
+	}
+
+	// Subscribing before getting the first leaderboard makes sure no change in between is missed.
+	// Only the latest change matters, as the whole leaderboard is sent again.
+	changes, unsubscribe := h.matchService.SubscribeToStandings(req.ClubId, req.GameId, 1)
+	defer unsubscribe()
+
+	lboard, err := h.leaderboardService.GetLeaderboard(ctx, req.ClubId, req.GameId, req.LeaderboardType, req.MinGames, nil, nil, req.Since, page)
+	if errors.Is(err, leaderboard.ErrNotRanked) {
+		return echo.NewHTTPError(http.StatusNotFound, errors.Cause(err).Error())
+	}
+
+	if err != nil {
+		h.logger.Error("failed to get leaderboard",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	if err := sendEvent(res, "leaderboard", lboard); err != nil {
+		return nil
+	}
+
+	keepAlive := time.NewTicker(leaderboardStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			// Comments are ignored by clients, but keep proxies from closing a quiet connection.
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+
+			res.Flush()
+		case <-changes:
+			lboard, err := h.leaderboardService.GetLeaderboard(ctx, req.ClubId, req.GameId, req.LeaderboardType, req.MinGames, nil, nil, req.Since, page)
+			if errors.Is(err, leaderboard.ErrNotRanked) {
+				continue
+			}
+
+			if err != nil {
+				h.logger.Error("failed to get leaderboard",
+					"error", err)
+				return nil
+			}
+
+			if err := sendEvent(res, "leaderboard", lboard); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// sendEvent writes data as JSON in a Server-Sent Event of the given name.
+func sendEvent(res *echo.Response, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", name, payload); err != nil {
+		return errors.Wrap(err, "failed to write event")
+	}
+
+	res.Flush()
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/live"
+	"matchlog/internal/match"
+	"matchlog/internal/rest/handlers"
+	"matchlog/internal/rest/helpers"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/net/websocket"
+)
+
+func (h *Handlers) StartLiveMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint   `json:"clubId" validate:"required,gt=0"`
+		GameId uint   `json:"gameId" validate:"required,gt=0"`
+		TeamA  []uint `json:"teamA" validate:"required"`
+		TeamB  []uint `json:"teamB" validate:"required"`
+		Rated  bool   `json:"rated"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	m, err := h.liveService.StartMatch(ctx, c.Claims.UserId, req.ClubId, req.GameId, req.TeamA, req.TeamB, req.Rated)
+	if err != nil {
+		return h.liveResponseError(err, "failed to start live match")
+	}
+
+	return c.JSON(http.StatusCreated, m)
+}
+
+func (h *Handlers) GetLiveMatches(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint `query:"clubId" validate:"required,gt=0"`
+	}
+
+	type response struct {
+		Matches []live.Match `json:"matches"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	matches, err := h.liveService.GetMatchesInClub(ctx, req.ClubId)
+	if err != nil {
+		return h.liveResponseError(err, "failed to get live matches")
+	}
+
+	resp := response{
+		Matches: matches,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) GetLiveMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		LiveId uint `param:"liveId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	m, err := h.authorizeLiveView(ctx, c.Claims.UserId, req.LiveId)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, m)
+}
+
+func (h *Handlers) PushLiveEvent(c handlers.AuthenticatedContext) error {
+	type request struct {
+		LiveId uint           `param:"liveId" validate:"required,gt=0"`
+		Type   live.EventType `json:"type" validate:"required,oneof=point set"`
+		Side   live.Side      `json:"side" validate:"omitempty,oneof=A B"`
+		Points int            `json:"points" default:"1"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	event := live.Event{
+		Type:   req.Type,
+		Side:   req.Side,
+		Points: req.Points,
+	}
+
+	m, err := h.liveService.PushEvent(ctx, req.LiveId, c.Claims.UserId, event)
+	if err != nil {
+		return h.liveResponseError(err, "failed to push live event")
+	}
+
+	return c.JSON(http.StatusOK, m)
+}
+
+func (h *Handlers) FinishLiveMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		LiveId uint `param:"liveId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.liveService.FinishMatch(ctx, req.LiveId, c.Claims.UserId); err != nil {
+		return h.liveResponseError(err, "failed to finish live match")
+	}
+
+	return c.NoContent(http.StatusCreated)
+}
+
+func (h *Handlers) CancelLiveMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		LiveId uint `param:"liveId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.liveService.CancelMatch(ctx, req.LiveId, c.Claims.UserId); err != nil {
+		return h.liveResponseError(err, "failed to cancel live match")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// WatchLiveMatch streams the state of a live match over a WebSocket until it is finished or cancelled.
+func (h *Handlers) WatchLiveMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		LiveId uint `param:"liveId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if _, err := h.authorizeLiveView(ctx, c.Claims.UserId, req.LiveId); err != nil {
+		return err
+	}
+
+	updates, unsubscribe, err := h.liveService.Subscribe(ctx, req.LiveId)
+	if err != nil {
+		return h.liveResponseError(err, "failed to subscribe to live match")
+	}
+	defer unsubscribe()
+
+	// Origins are not checked, in line with the CORS policy of the API.
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// Nothing is expected from the client, reading only tells when it goes away.
+			closed := make(chan struct{})
+			go func() {
+				_, _ = io.Copy(io.Discard, ws)
+				close(closed)
+			}()
+
+			for {
+				select {
+				case <-closed:
+					return
+				case m, ok := <-updates:
+					if !ok {
+						return
+					}
+
+					if err := websocket.JSON.Send(ws, m); err != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+
+	server.ServeHTTP(c.Response(), c.Request())
+
+	return nil
+}
+
+// authorizeLiveView returns the live match if the user is a member of the club it is played in.
+func (h *Handlers) authorizeLiveView(ctx context.Context, userId, liveId uint) (*live.Match, error) {
+	m, err := h.liveService.GetMatch(ctx, liveId)
+	if err != nil {
+		return nil, h.liveResponseError(err, "failed to get live match")
+	}
+
+	if err := h.authorizeClub(ctx, userId, m.ClubId, club.ActionView); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (h *Handlers) liveResponseError(err error, msg string) error {
+	switch {
+	case errors.Is(err, live.ErrNotFound):
+		return echo.ErrNotFound
+	case errors.Is(err, live.ErrNotAllowed), errors.Is(err, match.ErrNotClubMember):
+		return echo.ErrForbidden
+	case errors.Is(err, live.ErrFinishing):
+		return echo.ErrConflict
+	case errors.Is(err, live.ErrInvalidEvent), errors.Is(err, match.ErrGameNotInClub):
+		return echo.ErrBadRequest
+	case errors.Is(err, game.ErrInvalidScores):
+		// The reason is passed on, so the score can be corrected before finishing again.
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error(msg,
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+}
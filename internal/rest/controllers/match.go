@@ -1,22 +1,45 @@
 package controllers
 
 import (
+	"context"
+	"matchlog/internal/club"
+	"matchlog/internal/game"
 	"matchlog/internal/match"
 	"matchlog/internal/rest/handlers"
 	"matchlog/internal/rest/helpers"
-	"matchlog/internal/statistic"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
 func (h *Handlers) PostMatch(c handlers.AuthenticatedContext) error {
 	type request struct {
-		TeamA   []uint `json:"teamA" validate:"required"`
-		TeamB   []uint `json:"teamB" validate:"required"`
-		ScoresA []int  `json:"scoresA" validate:"required"`
-		ScoresB []int  `json:"scoresB" validate:"required"`
-		Rated   bool   `json:"rated" validate:"required"`
+		ClubId     uint   `json:"clubId" validate:"required,gt=0"`
+		GameId     uint   `json:"gameId" validate:"required,gt=0"`
+		TeamA      []uint `json:"teamA"`
+		TeamB      []uint `json:"teamB"`
+		ScoresA    []int  `json:"scoresA"`
+		ScoresB    []int  `json:"scoresB"`
+		Players    []uint `json:"players"`
+		Placements []int  `json:"placements"`
+		Scores     []int  `json:"scores"`
+		Won        *bool  `json:"won"`
+		Rated      bool   `json:"rated"`
+		Events     []struct {
+			Type       match.EventType `json:"type" validate:"required,oneof=goal timeout swap"`
+			Set        int             `json:"set" validate:"required,gt=0"`
+			Side       match.Side      `json:"side" validate:"omitempty,oneof=A B"`
+			UserId     uint            `json:"userId"`
+			Position   string          `json:"position"`
+			OccurredAt time.Time       `json:"occurredAt" validate:"required"`
+		} `json:"events" validate:"omitempty,dive"`
+	}
+
+	type response struct {
+		Id     uint   `json:"id"`
+		Status string `json:"status"`
 	}
 
 	ctx := c.Request().Context()
@@ -26,49 +49,458 @@ func (h *Handlers) PostMatch(c handlers.AuthenticatedContext) error {
 		return echo.ErrBadRequest
 	}
 
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionRecordMatches); err != nil {
+		return err
+	}
+
 	if len(req.ScoresA) != len(req.ScoresB) {
 		return echo.ErrBadRequest
 	}
 
-	result, winners, losers := h.matchService.DetermineResult(ctx, req.TeamA, req.TeamB, req.ScoresA, req.ScoresB)
+	idempotencyKey := c.Request().Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > 255 {
+		return echo.ErrBadRequest
+	}
+
+	submission := match.Submission{
+		IdempotencyKey: idempotencyKey,
+		SubmittedBy:    c.Claims.UserId,
+		ClubId:         req.ClubId,
+		GameId:         req.GameId,
+		TeamA:          req.TeamA,
+		TeamB:          req.TeamB,
+		ScoresA:        req.ScoresA,
+		ScoresB:        req.ScoresB,
+		Players:        req.Players,
+		Placements:     req.Placements,
+		Scores:         req.Scores,
+		Won:            req.Won,
+		Rated:          req.Rated,
+	}
+
+	for _, event := range req.Events {
+		submission.Events = append(submission.Events, match.Event{
+			Type:       event.Type,
+			Set:        event.Set,
+			Side:       event.Side,
+			UserId:     event.UserId,
+			Position:   event.Position,
+			OccurredAt: event.OccurredAt,
+		})
+	}
+
+	m, err := h.matchService.CreateMatch(ctx, submission)
+	if err != nil {
+		return h.matchResponseError(err, "failed to create match")
+	}
+
+	resp := response{
+		Id:     m.Id,
+		Status: string(m.Status),
+	}
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handlers) GetMatches(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId     uint       `query:"clubId" validate:"required,gt=0"`
+		GameId     uint       `query:"gameId"`
+		PlayerId   uint       `query:"playerId"`
+		OpponentId uint       `query:"opponentId"`
+		PartnerId  uint       `query:"partnerId"`
+		From       *time.Time `query:"from"`
+		To         *time.Time `query:"to"`
+		Rated      *bool      `query:"rated"`
+		Status     string     `query:"status" validate:"omitempty,oneof=pending confirmed disputed"`
+		Cursor     string     `query:"cursor"`
+		Limit      int        `query:"limit" default:"20" validate:"omitempty,gt=0,lte=100"`
+	}
+
+	type responsePlayer struct {
+		Id   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+
+	type responseMatch struct {
+		Id         uint             `json:"id"`
+		GameId     uint             `json:"gameId"`
+		Type       string           `json:"type"`
+		TeamA      []responsePlayer `json:"teamA"`
+		TeamB      []responsePlayer `json:"teamB"`
+		Sets       []string         `json:"sets"`
+		Placements []int            `json:"placements,omitempty"`
+		Scores     []int            `json:"scores,omitempty"`
+		Result     string           `json:"result"`
+		Rated      bool             `json:"rated"`
+		Status     string           `json:"status"`
+		CreatedAt  time.Time        `json:"createdAt"`
+	}
+
+	type response struct {
+		Matches    []responseMatch `json:"matches"`
+		NextCursor string          `json:"nextCursor,omitempty"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	if (req.OpponentId != 0 || req.PartnerId != 0) && req.PlayerId == 0 {
+		return echo.ErrBadRequest
+	}
+
+	filter := match.ListFilter{
+		ClubId:     req.ClubId,
+		GameId:     req.GameId,
+		PlayerId:   req.PlayerId,
+		OpponentId: req.OpponentId,
+		PartnerId:  req.PartnerId,
+		From:       req.From,
+		To:         req.To,
+		Rated:      req.Rated,
+		Status:     match.Status(req.Status),
+		Cursor:     req.Cursor,
+		Limit:      req.Limit,
+	}
+
+	matches, nextCursor, err := h.matchService.ListMatches(ctx, filter)
+	if errors.Is(err, match.ErrInvalidCursor) {
+		return echo.ErrBadRequest
+	}
+
+	if err != nil {
+		h.logger.Error("failed to list matches",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	var userIds []uint
+	for _, m := range matches {
+		userIds = append(userIds, m.TeamA...)
+		userIds = append(userIds, m.TeamB...)
+	}
 
-	if err = h.matchService.CreateMatch(ctx, req.TeamA, req.TeamB, req.ScoresA, req.ScoresB, result); err != nil {
-		h.logger.Error("failed to create match",
+	users, err := h.userService.GetUsers(ctx, userIds)
+	if err != nil {
+		h.logger.Error("failed to get users",
 			"error", err)
 		return echo.ErrInternalServerError
 	}
 
-	if result == match.Draw {
-		allPlayers := append(req.TeamA, req.TeamB...)
-		if err := h.statisticService.UpdateStatisticsByUserIds(ctx, allPlayers, statistic.ResultDraw); err != nil {
-			h.logger.Error("failed to update statistics for draw",
-				"error", err)
-			return echo.ErrInternalServerError
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.Id] = u.Name
+	}
+
+	toPlayers := func(ids []uint) []responsePlayer {
+		players := make([]responsePlayer, len(ids))
+		for i, id := range ids {
+			players[i] = responsePlayer{
+				Id:   id,
+				Name: names[id],
+			}
 		}
-	} else {
-		if err := h.statisticService.UpdateStatisticsByUserIds(ctx, winners, statistic.ResultWin); err != nil {
-			h.logger.Error("failed to update statistics for winners",
-				"error", err)
-			return echo.ErrInternalServerError
+
+		return players
+	}
+
+	respMatches := make([]responseMatch, len(matches))
+	for i, m := range matches {
+		respMatches[i] = responseMatch{
+			Id:         m.Id,
+			GameId:     m.GameId,
+			Type:       string(m.Type),
+			TeamA:      toPlayers(m.TeamA),
+			TeamB:      toPlayers(m.TeamB),
+			Sets:       m.Sets,
+			Placements: m.Placements,
+			Scores:     m.Scores,
+			Result:     string(m.Result),
+			Rated:      m.Rated,
+			Status:     string(m.Status),
+			CreatedAt:  m.CreatedAt,
 		}
+	}
+
+	resp := response{
+		Matches:    respMatches,
+		NextCursor: nextCursor,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) GetMatchEvents(c handlers.AuthenticatedContext) error {
+	type request struct {
+		MatchId uint `param:"matchId" validate:"required,gt=0"`
+	}
+
+	type responseEvent struct {
+		Type       string    `json:"type"`
+		Set        int       `json:"set"`
+		Side       string    `json:"side"`
+		UserId     uint      `json:"userId,omitempty"`
+		Position   string    `json:"position,omitempty"`
+		OccurredAt time.Time `json:"occurredAt"`
+	}
+
+	type response struct {
+		Events []responseEvent `json:"events"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeMatchView(ctx, c.Claims.UserId, req.MatchId); err != nil {
+		return err
+	}
+
+	events, err := h.matchService.GetEvents(ctx, req.MatchId)
+	if err != nil {
+		return h.matchResponseError(err, "failed to get match events")
+	}
 
-		if err := h.statisticService.UpdateStatisticsByUserIds(ctx, losers, statistic.ResultLoss); err != nil {
-			h.logger.Error("failed to update statistics for losers",
-				"error", err)
-			return echo.ErrInternalServerError
+	respEvents := make([]responseEvent, len(events))
+	for i, event := range events {
+		respEvents[i] = responseEvent{
+			Type:       string(event.Type),
+			Set:        event.Set,
+			Side:       string(event.Side),
+			UserId:     event.UserId,
+			Position:   event.Position,
+			OccurredAt: event.OccurredAt,
 		}
 	}
 
-	if !req.Rated {
-		return c.NoContent(http.StatusCreated)
+	resp := response{
+		Events: respEvents,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) GetMatchSummary(c handlers.AuthenticatedContext) error {
+	type request struct {
+		MatchId uint `param:"matchId" validate:"required,gt=0"`
+	}
+
+	type response struct {
+		Summary match.Summary `json:"summary"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeMatchView(ctx, c.Claims.UserId, req.MatchId); err != nil {
+		return err
 	}
 
-	isDraw := result == match.Draw
-	if err := h.ratingService.UpdateRatings(ctx, isDraw, winners, losers); err != nil {
-		h.logger.Error("failed to update ratings",
+	summary, err := h.matchService.GetSummary(ctx, req.MatchId)
+	if err != nil {
+		return h.matchResponseError(err, "failed to get match summary")
+	}
+
+	resp := response{
+		Summary: *summary,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) UpdateMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		MatchId    uint   `param:"matchId" validate:"required,gt=0"`
+		TeamA      []uint `json:"teamA"`
+		TeamB      []uint `json:"teamB"`
+		ScoresA    []int  `json:"scoresA"`
+		ScoresB    []int  `json:"scoresB"`
+		Players    []uint `json:"players"`
+		Placements []int  `json:"placements"`
+		Scores     []int  `json:"scores"`
+		Won        *bool  `json:"won"`
+		Rated      bool   `json:"rated"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if len(req.ScoresA) != len(req.ScoresB) {
+		return echo.ErrBadRequest
+	}
+
+	moderated, err := h.authorizeMatchChange(ctx, c.Claims.UserId, req.MatchId)
+	if err != nil {
+		return err
+	}
+
+	correction := match.Submission{
+		TeamA:      req.TeamA,
+		TeamB:      req.TeamB,
+		ScoresA:    req.ScoresA,
+		ScoresB:    req.ScoresB,
+		Players:    req.Players,
+		Placements: req.Placements,
+		Scores:     req.Scores,
+		Won:        req.Won,
+		Rated:      req.Rated,
+	}
+
+	if err := h.matchService.UpdateMatch(ctx, req.MatchId, correction, moderated); err != nil {
+		return h.matchResponseError(err, "failed to update match")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) DeleteMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		MatchId uint `param:"matchId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	moderated, err := h.authorizeMatchChange(ctx, c.Claims.UserId, req.MatchId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := h.matchService.DeleteMatch(ctx, req.MatchId, moderated)
+	if err != nil {
+		h.logger.Error("failed to delete match",
 			"error", err)
 		return echo.ErrInternalServerError
 	}
 
-	return c.NoContent(http.StatusCreated)
+	// A confirmed match is only taken back to pending, until the opposing side agrees.
+	if !deleted {
+		return c.NoContent(http.StatusAccepted)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) ConfirmMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		MatchId uint `param:"matchId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.matchService.ConfirmMatch(ctx, req.MatchId, c.Claims.UserId); err != nil {
+		return h.matchResponseError(err, "failed to confirm match")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) DisputeMatch(c handlers.AuthenticatedContext) error {
+	type request struct {
+		MatchId uint `param:"matchId" validate:"required,gt=0"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.matchService.DisputeMatch(ctx, req.MatchId, c.Claims.UserId); err != nil {
+		return h.matchResponseError(err, "failed to dispute match")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) matchResponseError(err error, msg string) error {
+	switch {
+	case errors.Is(err, match.ErrNotFound):
+		return echo.ErrNotFound
+	case errors.Is(err, match.ErrNotAllowed):
+		return echo.ErrForbidden
+	case errors.Is(err, match.ErrNotPending):
+		return echo.ErrConflict
+	case errors.Is(err, match.ErrGameNotInClub):
+		return echo.ErrBadRequest
+	case errors.Is(err, game.ErrInvalidScores), errors.Is(err, match.ErrInvalidEvents), errors.Is(err, match.ErrInvalidSubmission):
+		// The reason is passed on, so the result can be corrected.
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, match.ErrNotClubMember):
+		return echo.ErrForbidden
+	case errors.Is(err, match.ErrIdempotencyKeyReused):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	default:
+		h.logger.Error(msg,
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+}
+
+// authorizeMatchView only lets members of the club a match was played in see it.
+func (h *Handlers) authorizeMatchView(ctx context.Context, userId, matchId uint) error {
+	m, err := h.matchService.GetMatch(ctx, matchId)
+	if err != nil {
+		return h.matchResponseError(err, "failed to get match")
+	}
+
+	return h.authorizeClub(ctx, userId, m.ClubId, club.ActionView)
+}
+
+// authorizeMatchChange only lets the submitter of a match, or a manager or admin of its club, change it.
+// Whether the change is moderated by a manager or admin is returned, as the submitter alone can not change a confirmed result.
+func (h *Handlers) authorizeMatchChange(ctx context.Context, userId, matchId uint) (bool, error) {
+	m, err := h.matchService.GetMatch(ctx, matchId)
+	if err != nil {
+		if errors.Is(err, match.ErrNotFound) {
+			return false, echo.ErrNotFound
+		}
+
+		h.logger.Error("failed to get match",
+			"error", err)
+		return false, echo.ErrInternalServerError
+	}
+
+	_, err = h.clubService.Authorize(ctx, userId, m.ClubId, club.ActionModerateMatches)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, club.ErrForbidden) && m.SubmittedBy == userId:
+		return false, nil
+	case errors.Is(err, club.ErrForbidden):
+		return false, echo.ErrForbidden
+	default:
+		h.logger.Error("failed to authorize club action",
+			"error", err)
+		return false, echo.ErrInternalServerError
+	}
 }
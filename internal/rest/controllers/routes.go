@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"matchlog/internal/achievement"
 	"matchlog/internal/authentication"
 	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/importer"
 	"matchlog/internal/leaderboard"
+	"matchlog/internal/live"
 	"matchlog/internal/match"
 	"matchlog/internal/rating"
 	"matchlog/internal/rest/handlers"
 	"matchlog/internal/rest/middleware"
 	"matchlog/internal/statistic"
+	"matchlog/internal/team"
 	"matchlog/internal/user"
 
 	"github.com/labstack/echo/v4"
@@ -24,6 +29,11 @@ type Handlers struct {
 	ratingService      rating.Service
 	statisticService   statistic.Service
 	leaderboardService leaderboard.Service
+	teamService        team.Service
+	gameService        game.Service
+	liveService        live.Service
+	importerService    importer.Service
+	achievementService achievement.Service
 }
 
 func Register(
@@ -36,6 +46,11 @@ func Register(
 	ratingService rating.Service,
 	statisticService statistic.Service,
 	leaderboardService leaderboard.Service,
+	teamService team.Service,
+	gameService game.Service,
+	liveService live.Service,
+	importerService importer.Service,
+	achievementService achievement.Service,
 ) {
 	h := &Handlers{
 		logger:             logger,
@@ -46,6 +61,11 @@ func Register(
 		ratingService:      ratingService,
 		statisticService:   statisticService,
 		leaderboardService: leaderboardService,
+		teamService:        teamService,
+		gameService:        gameService,
+		liveService:        liveService,
+		importerService:    importerService,
+		achievementService: achievementService,
 	}
 
 	authHandler := handlers.AuthenticatedHandlerFactory(logger)
@@ -63,6 +83,25 @@ func Register(
 	userGroup.DELETE("/user", authHandler(h.DeleteUser))
 	userGroup.GET("/user/invites", authHandler(h.GetUserInvites))
 	userGroup.POST("/user/invites/:inviteId", authHandler(h.RespondToInvite))
+	userGroup.GET("/user/privacy", authHandler(h.GetPrivacy))
+	userGroup.PUT("/user/privacy", authHandler(h.UpdatePrivacy))
+
+	usersGroup := e.Group("/users", authGuard)
+	usersGroup.GET("/:userId/head-to-head/:otherUserId", authHandler(h.GetHeadToHead))
+	usersGroup.GET("/:userId/partners", authHandler(h.GetPartners))
+	usersGroup.GET("/:userId/statistics", authHandler(h.GetUserStatistics))
+	usersGroup.GET("/:userId/achievements", authHandler(h.GetUserAchievements))
+
+	// Leaderboards across clubs
+	leaderboardsGroup := e.Group("/leaderboards", authGuard)
+	leaderboardsGroup.GET("/global", authHandler(h.GetGlobalLeaderboard))
+	leaderboardsGroup.GET("/clubs", authHandler(h.GetClubsLeaderboard))
+
+	// Finding and joining clubs
+	clubsGroup := e.Group("/clubs", authGuard)
+	clubsGroup.GET("", authHandler(h.SearchClubs))
+	clubsGroup.POST("/join", authHandler(h.JoinWithInviteLink))
+	clubsGroup.POST("/:clubId/join-requests", authHandler(h.RequestToJoinClub))
 
 	// Clubs
 	clubGroup := e.Group("/club", authGuard)
@@ -71,10 +110,39 @@ func Register(
 	clubGroup.DELETE("", authHandler(h.DeleteClub))
 	clubGroup.GET("/users", authHandler(h.GetUsersInClub))
 	clubGroup.POST("/invite", authHandler(h.InviteUsersToClub))
+	clubGroup.GET("/invites", authHandler(h.GetInvitesInClub))
+	clubGroup.DELETE("/invites/:inviteId", authHandler(h.RevokeInvite))
+	clubGroup.POST("/links", authHandler(h.CreateInviteLink))
+	clubGroup.GET("/links", authHandler(h.GetInviteLinks))
+	clubGroup.DELETE("/links/:linkId", authHandler(h.RevokeInviteLink))
+	clubGroup.GET("/join-requests", authHandler(h.GetJoinRequests))
+	clubGroup.POST("/join-requests/:requestId", authHandler(h.ReviewJoinRequest))
 	clubGroup.POST("/users/virtual", authHandler(h.AddVirtualUserToClub))
 	//clubGroup.POST("/users/:userId/virtual/:virtualUserId", authHandler(h.TransferVirtualUserToUser))
 	clubGroup.DELETE("/users/:userId", authHandler(h.RemoveUserFromClub))
 	clubGroup.PUT("/users/:userId", authHandler(h.UpdateUserRole))
-	clubGroup.GET("/top/:topX/measures/:leaderboardType", authHandler(h.GetLeaderboard))
+	clubGroup.GET("/leaderboard", authHandler(h.GetLeaderboard))
+	clubGroup.GET("/top/:topX/measures/:leaderboardType", authHandler(h.GetTopX))
+	clubGroup.GET("/leaderboard/stream", authHandler(h.StreamLeaderboard))
 	clubGroup.POST("/matches", authHandler(h.PostMatch))
+	clubGroup.GET("/matches", authHandler(h.GetMatches))
+	clubGroup.POST("/matches/import", authHandler(h.ImportMatches))
+	clubGroup.PUT("/matches/:matchId", authHandler(h.UpdateMatch))
+	clubGroup.DELETE("/matches/:matchId", authHandler(h.DeleteMatch))
+	clubGroup.POST("/matches/:matchId/confirm", authHandler(h.ConfirmMatch))
+	clubGroup.POST("/matches/:matchId/dispute", authHandler(h.DisputeMatch))
+	clubGroup.GET("/matches/:matchId/events", authHandler(h.GetMatchEvents))
+	clubGroup.GET("/matches/:matchId/summary", authHandler(h.GetMatchSummary))
+	clubGroup.GET("/teams", authHandler(h.GetTeamsInClub))
+	clubGroup.POST("/games", authHandler(h.AddGameToClub))
+	clubGroup.GET("/games", authHandler(h.GetGamesInClub))
+	clubGroup.POST("/live", authHandler(h.StartLiveMatch))
+	clubGroup.GET("/live", authHandler(h.GetLiveMatches))
+	clubGroup.GET("/live/:liveId", authHandler(h.GetLiveMatch))
+	clubGroup.POST("/live/:liveId/events", authHandler(h.PushLiveEvent))
+	clubGroup.POST("/live/:liveId/finish", authHandler(h.FinishLiveMatch))
+	clubGroup.DELETE("/live/:liveId", authHandler(h.CancelLiveMatch))
+
+	// Live matches are watched over a WebSocket, which can not carry the Authorization header
+	e.GET("/club/live/:liveId/ws", authHandler(h.WatchLiveMatch), middleware.WebSocketAuthGuard(authService))
 }
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"matchlog/internal/club"
+	"matchlog/internal/rest/handlers"
+	"matchlog/internal/rest/helpers"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handlers) GetTeamsInClub(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ClubId uint `query:"clubId" validate:"required,gt=0"`
+		GameId uint `query:"gameId" validate:"required,gt=0"`
+	}
+
+	type responseTeam struct {
+		Id      uint    `json:"id"`
+		UserIds []uint  `json:"userIds"`
+		Wins    int     `json:"wins"`
+		Draws   int     `json:"draws"`
+		Losses  int     `json:"losses"`
+		Streak  int     `json:"streak"`
+		Rating  float64 `json:"rating"`
+	}
+
+	type response struct {
+		Teams []responseTeam `json:"teams"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	teams, err := h.teamService.GetTeamsInClub(ctx, req.ClubId)
+	if err != nil {
+		h.logger.Error("failed to get teams in Club",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	teamIds := make([]uint, len(teams))
+	for i, t := range teams {
+		teamIds[i] = t.Id
+	}
+
+	stats, err := h.statisticService.GetStatisticsByTeamIds(ctx, req.ClubId, req.GameId, teamIds)
+	if err != nil {
+		h.logger.Error("failed to get team statistics",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	ratings, err := h.ratingService.GetRatingsByTeamIds(ctx, req.ClubId, req.GameId, teamIds)
+	if err != nil {
+		h.logger.Error("failed to get team ratings",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	respTeams := make(map[uint]*responseTeam, len(teams))
+	for _, t := range teams {
+		respTeams[t.Id] = &responseTeam{
+			Id:      t.Id,
+			UserIds: t.UserIds,
+		}
+	}
+
+	for _, stat := range stats {
+		if t, ok := respTeams[stat.TeamId]; ok {
+			t.Wins = stat.Wins
+			t.Draws = stat.Draws
+			t.Losses = stat.Losses
+			t.Streak = stat.Streak
+		}
+	}
+
+	for _, r := range ratings {
+		if t, ok := respTeams[r.TeamId]; ok {
+			t.Rating = r.Value
+		}
+	}
+
+	resp := response{
+		Teams: make([]responseTeam, len(teams)),
+	}
+	for i, t := range teams {
+		resp.Teams[i] = *respTeams[t.Id]
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -1,11 +1,17 @@
 package controllers
 
 import (
+	"matchlog/internal/achievement"
+	"matchlog/internal/club"
+	"matchlog/internal/match"
 	"matchlog/internal/rest/handlers"
 	"matchlog/internal/rest/helpers"
+	"matchlog/internal/statistic"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
 func (h *Handlers) DeleteUser(c handlers.AuthenticatedContext) error {
@@ -20,10 +26,54 @@ func (h *Handlers) DeleteUser(c handlers.AuthenticatedContext) error {
 	return c.NoContent(http.StatusOK)
 }
 
+func (h *Handlers) GetPrivacy(c handlers.AuthenticatedContext) error {
+	type response struct {
+		ShowOnGlobalLeaderboards bool `json:"showOnGlobalLeaderboards"`
+	}
+
+	ctx := c.Request().Context()
+
+	u, err := h.userService.GetUser(ctx, c.Claims.UserId)
+	if err != nil {
+		h.logger.Error("failed to get user",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	resp := response{
+		ShowOnGlobalLeaderboards: u.ShowOnGlobalLeaderboards,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) UpdatePrivacy(c handlers.AuthenticatedContext) error {
+	type request struct {
+		ShowOnGlobalLeaderboards *bool `json:"showOnGlobalLeaderboards" validate:"required"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.userService.SetShowOnGlobalLeaderboards(ctx, c.Claims.UserId, *req.ShowOnGlobalLeaderboards); err != nil {
+		h.logger.Error("failed to update privacy settings",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// RemoveUserFromClub removes a member from the Club. Anyone can leave a Club themselves,
+// but only as long as they are not its last admin.
 func (h *Handlers) RemoveUserFromClub(c handlers.AuthenticatedContext) error {
 	type request struct {
 		UserId uint `param:"userId" validate:"required,gt=0"`
-		ClubId uint `param:"clubId" validate:"required,gt=0"`
+		ClubId uint `json:"clubId" validate:"required,gt=0"`
 	}
 
 	ctx := c.Request().Context()
@@ -33,7 +83,17 @@ func (h *Handlers) RemoveUserFromClub(c handlers.AuthenticatedContext) error {
 		return echo.ErrBadRequest
 	}
 
+	if req.UserId != c.Claims.UserId {
+		if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionRemoveMember); err != nil {
+			return err
+		}
+	}
+
 	if err := h.clubService.RemoveUserFromClub(ctx, req.UserId, req.ClubId); err != nil {
+		if errors.Is(err, club.ErrLastAdmin) {
+			return echo.NewHTTPError(http.StatusConflict, club.ErrLastAdmin.Error())
+		}
+
 		h.logger.Error("failed to remove user from Club",
 			"error", err)
 		return echo.ErrInternalServerError
@@ -53,7 +113,7 @@ func (h *Handlers) AddVirtualUserToClub(c handlers.AuthenticatedContext) error {
 	if err != nil {
 		return echo.ErrBadRequest
 	}
-	if err := h.userService.CreateVirtualUser(ctx, req.Name); err != nil {
+	if _, err := h.userService.CreateVirtualUser(ctx, req.Name); err != nil {
 		h.logger.Error("failed to create virtual user",
 			"error", err)
 		return echo.ErrInternalServerError
@@ -63,24 +123,272 @@ func (h *Handlers) AddVirtualUserToClub(c handlers.AuthenticatedContext) error {
 }
 
 func (h *Handlers) RespondToInvite(c handlers.AuthenticatedContext) error {
-	/*
-		type request struct {
-			InviteId uint `param:"inviteId" validate:"required,gt=0"`
-			Accept   bool `json:"accept" validate:"required"`
-		}
+	type request struct {
+		InviteId uint  `param:"inviteId" validate:"required,gt=0"`
+		Accept   *bool `json:"accept" validate:"required"`
+	}
 
-		ctx := c.Request().Context()
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
 
-		req, err := helpers.Bind[request](c)
-		if err != nil {
-			return echo.ErrBadRequest
+	if err := h.clubService.RespondToInvite(ctx, c.Claims.UserId, req.InviteId, *req.Accept); err != nil {
+		return h.inviteResponseError(err, "failed to respond to invite")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handlers) GetHeadToHead(c handlers.AuthenticatedContext) error {
+	type request struct {
+		UserId      uint `param:"userId" validate:"required,gt=0"`
+		OtherUserId uint `param:"otherUserId" validate:"required,gt=0,nefield=UserId"`
+		ClubId      uint `query:"clubId"`
+		GameId      uint `query:"gameId"`
+	}
+
+	type response struct {
+		HeadToHead match.HeadToHead `json:"headToHead"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	clubIds, err := h.viewableClubIds(ctx, c.Claims.UserId, req.ClubId, req.UserId, req.OtherUserId)
+	if err != nil {
+		return err
+	}
+
+	record, err := h.matchService.GetHeadToHead(ctx, clubIds, req.GameId, req.UserId, req.OtherUserId)
+	if err != nil {
+		h.logger.Error("failed to get head-to-head record",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	resp := response{
+		HeadToHead: *record,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) GetPartners(c handlers.AuthenticatedContext) error {
+	type request struct {
+		UserId uint `param:"userId" validate:"required,gt=0"`
+		ClubId uint `query:"clubId"`
+		GameId uint `query:"gameId"`
+	}
+
+	type responsePartner struct {
+		match.Partnership
+		Name string `json:"name"`
+	}
+
+	type response struct {
+		Partners []responsePartner `json:"partners"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	clubIds, err := h.viewableClubIds(ctx, c.Claims.UserId, req.ClubId, req.UserId)
+	if err != nil {
+		return err
+	}
+
+	partnerships, err := h.matchService.GetPartners(ctx, clubIds, req.GameId, req.UserId)
+	if err != nil {
+		h.logger.Error("failed to get partners",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	partnerIds := make([]uint, len(partnerships))
+	for i, partnership := range partnerships {
+		partnerIds[i] = partnership.PartnerId
+	}
+
+	users, err := h.userService.GetUsers(ctx, partnerIds)
+	if err != nil {
+		h.logger.Error("failed to get users",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.Id] = u.Name
+	}
+
+	partners := make([]responsePartner, len(partnerships))
+	for i, partnership := range partnerships {
+		partners[i] = responsePartner{
+			Partnership: partnership,
+			Name:        names[partnership.PartnerId],
 		}
+	}
+
+	resp := response{
+		Partners: partners,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) GetUserStatistics(c handlers.AuthenticatedContext) error {
+	type request struct {
+		UserId uint             `param:"userId" validate:"required,gt=0"`
+		ClubId uint             `query:"clubId" validate:"required,gt=0"`
+		GameId uint             `query:"gameId" validate:"required,gt=0"`
+		From   *time.Time       `query:"from"`
+		To     *time.Time       `query:"to"`
+		Period statistic.Period `query:"period" validate:"omitempty,oneof=week month quarter year"`
+	}
+
+	type response struct {
+		Wins              int        `json:"wins"`
+		Draws             int        `json:"draws"`
+		Losses            int        `json:"losses"`
+		Streak            int        `json:"streak"`
+		WinRate           float64    `json:"winRate"`
+		GoalsFor          int        `json:"goalsFor"`
+		GoalsAgainst      int        `json:"goalsAgainst"`
+		SetsWon           int        `json:"setsWon"`
+		SetsLost          int        `json:"setsLost"`
+		LongestWinStreak  int        `json:"longestWinStreak"`
+		LongestLossStreak int        `json:"longestLossStreak"`
+		LastPlayedAt      *time.Time `json:"lastPlayedAt,omitempty"`
+		From              *time.Time `json:"from,omitempty"`
+		To                *time.Time `json:"to,omitempty"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	if err := h.authorizeClub(ctx, c.Claims.UserId, req.ClubId, club.ActionView); err != nil {
+		return err
+	}
+
+	from, to, err := resolveWindow(req.From, req.To, req.Period)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	resp := response{
+		From: from,
+		To:   to,
+	}
 
-		if err := h.clubService.RespondToInvite(ctx, req.InviteId, req.Accept); err != nil {
-			h.logger.Error("failed to respond to invite",
+	fill := func(stats statistic.Statistic) {
+		resp.Wins = stats.Wins
+		resp.Draws = stats.Draws
+		resp.Losses = stats.Losses
+		resp.Streak = stats.Streak
+		resp.WinRate = stats.WinRate()
+		resp.GoalsFor = stats.GoalsFor
+		resp.GoalsAgainst = stats.GoalsAgainst
+		resp.SetsWon = stats.SetsWon
+		resp.SetsLost = stats.SetsLost
+		resp.LongestWinStreak = stats.LongestWinStreak
+		resp.LongestLossStreak = stats.LongestLossStreak
+		resp.LastPlayedAt = stats.LastPlayedAt
+	}
+
+	// All-time statistics are kept up to date as matches are confirmed, any other window is computed from the matches in it.
+	if from == nil && to == nil {
+		stats, err := h.statisticService.GetStatisticByUserId(ctx, req.ClubId, req.GameId, req.UserId)
+		if err != nil && !errors.Is(err, statistic.ErrNotFound) {
+			h.logger.Error("failed to get user statistics",
 				"error", err)
 			return echo.ErrInternalServerError
 		}
-	*/
-	return c.NoContent(http.StatusOK)
+
+		if stats != nil {
+			fill(*stats)
+		}
+
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	stats, err := h.matchService.GetStatisticsBetween(ctx, req.ClubId, req.GameId, from, to)
+	if err != nil {
+		h.logger.Error("failed to get user statistics in window",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	for _, stat := range stats {
+		if stat.TeamId == 0 && stat.UserId == req.UserId {
+			fill(stat)
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handlers) GetUserAchievements(c handlers.AuthenticatedContext) error {
+	type request struct {
+		UserId uint `param:"userId" validate:"required,gt=0"`
+	}
+
+	type responseAchievement struct {
+		Badge       string    `json:"badge"`
+		Description string    `json:"description"`
+		ClubId      uint      `json:"clubId"`
+		GameId      uint      `json:"gameId"`
+		MatchId     uint      `json:"matchId"`
+		AwardedAt   time.Time `json:"awardedAt"`
+	}
+
+	type response struct {
+		Achievements []responseAchievement `json:"achievements"`
+	}
+
+	ctx := c.Request().Context()
+
+	req, err := helpers.Bind[request](c)
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+
+	achievements, err := h.achievementService.GetAchievements(ctx, req.UserId)
+	if err != nil {
+		h.logger.Error("failed to get achievements",
+			"error", err)
+		return echo.ErrInternalServerError
+	}
+
+	respAchievements := make([]responseAchievement, len(achievements))
+	for i, a := range achievements {
+		respAchievements[i] = responseAchievement{
+			Badge:       string(a.Badge),
+			Description: achievement.Describe(a.Badge),
+			ClubId:      a.ClubId,
+			GameId:      a.GameId,
+			MatchId:     a.MatchId,
+			AwardedAt:   a.AwardedAt,
+		}
+	}
+
+	resp := response{
+		Achievements: respAchievements,
+	}
+
+	return c.JSON(http.StatusOK, resp)
 }
@@ -20,16 +20,35 @@ func AuthGuard(authenticationService authentication.Service) func(next echo.Hand
 				return echo.ErrUnauthorized
 			}
 
-			valid, claims, err := authenticationService.VerifyAccessToken(c.Request().Context(), token)
-			if !valid || err != nil {
+			return authenticate(c, authenticationService, token, next)
+		}
+	}
+
+}
+
+// WebSocketAuthGuard reads the access token from the token query parameter instead,
+// as browsers can not set headers when opening a WebSocket.
+func WebSocketAuthGuard(authenticationService authentication.Service) func(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.QueryParam("token")
+			if token == "" {
 				return echo.ErrUnauthorized
 			}
 
-			c.Set("jwt", token)
-			c.Set("jwt_claims", claims)
-
-			return next(c)
+			return authenticate(c, authenticationService, token, next)
 		}
 	}
+}
+
+func authenticate(c echo.Context, authenticationService authentication.Service, token string, next echo.HandlerFunc) error {
+	valid, claims, err := authenticationService.VerifyAccessToken(c.Request().Context(), token)
+	if !valid || err != nil {
+		return echo.ErrUnauthorized
+	}
+
+	c.Set("jwt", token)
+	c.Set("jwt_claims", claims)
 
+	return next(c)
 }
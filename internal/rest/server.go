@@ -2,16 +2,23 @@ package rest
 
 import (
 	"context"
+	"expvar"
 	"fmt"
+	"matchlog/internal/achievement"
 	"matchlog/internal/authentication"
 	"matchlog/internal/club"
+	"matchlog/internal/game"
+	"matchlog/internal/importer"
 	"matchlog/internal/leaderboard"
+	"matchlog/internal/live"
 	"matchlog/internal/match"
 	"matchlog/internal/rating"
 	"matchlog/internal/rest/controllers"
 	"matchlog/internal/rest/helpers"
 	"matchlog/internal/statistic"
+	"matchlog/internal/team"
 	"matchlog/internal/user"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -24,8 +31,8 @@ type Config struct {
 }
 
 type Server struct {
-	echo *echo.Echo
-	port int
+	echo    *echo.Echo
+	address string
 }
 
 func NewServer(
@@ -38,6 +45,11 @@ func NewServer(
 	ratingService rating.Service,
 	statisticService statistic.Service,
 	leaderboardService leaderboard.Service,
+	teamService team.Service,
+	gameService game.Service,
+	liveService live.Service,
+	importerService importer.Service,
+	achievementService achievement.Service,
 ) (*Server, error) {
 	e := echo.New()
 
@@ -52,7 +64,10 @@ func NewServer(
 			AllowOrigins: []string{"*"},
 		}),
 		middleware.GzipWithConfig(middleware.GzipConfig{
-			Skipper: middleware.DefaultGzipConfig.Skipper,
+			// Compressing a stream of events would hold them back until enough of them had been buffered.
+			Skipper: func(c echo.Context) bool {
+				return strings.HasSuffix(c.Path(), "/stream")
+			},
 		}),
 	)
 
@@ -68,16 +83,37 @@ func NewServer(
 		ratingService,
 		statisticService,
 		leaderboardService,
+		teamService,
+		gameService,
+		liveService,
+		importerService,
+		achievementService,
 	)
 
 	return &Server{
-		echo: e,
-		port: port,
+		echo:    e,
+		address: fmt.Sprintf("0.0.0.0:%d", port),
 	}, nil
 }
 
+// NewDebugServer serves the counters published through expvar, such as those of the leaderboard cache.
+// They are not authenticated, so the address should only be reachable from inside, such as 127.0.0.1:6060.
+func NewDebugServer(address string) *Server {
+	e := echo.New()
+
+	e.HideBanner = true
+	e.HidePort = true
+
+	e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+
+	return &Server{
+		echo:    e,
+		address: address,
+	}
+}
+
 func (s *Server) Start() error {
-	err := s.echo.Start(fmt.Sprintf("0.0.0.0:%d", s.port))
+	err := s.echo.Start(s.address)
 	if err != nil {
 		return errors.Wrap(err, "Failed to start server")
 	}
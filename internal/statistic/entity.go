@@ -13,20 +13,151 @@ const (
 type Measure string
 
 const (
-	MeasureWins   Measure = "wins"
-	MeasureStreak Measure = "streak"
+	MeasureWins              Measure = "wins"
+	MeasureStreak            Measure = "streak"
+	MeasureGoalsFor          Measure = "goals-for"
+	MeasureGoalsAgainst      Measure = "goals-against"
+	MeasureGoalDifference    Measure = "goal-difference"
+	MeasureSetsWon           Measure = "sets-won"
+	MeasureSetsLost          Measure = "sets-lost"
+	MeasureWinRate           Measure = "winrate"
+	MeasureLongestWinStreak  Measure = "longest-win-streak"
+	MeasureLongestLossStreak Measure = "longest-loss-streak"
 )
 
+// LowerIsBetter tells whether the lowest value of the measure ranks first, as with goals conceded.
+func (m Measure) LowerIsBetter() bool {
+	switch m {
+	case MeasureGoalsAgainst, MeasureSetsLost, MeasureLongestLossStreak:
+		return true
+	default:
+		return false
+	}
+}
+
+// Better tells whether value a of the measure ranks above value b.
+func (m Measure) Better(a, b float64) bool {
+	if m.LowerIsBetter() {
+		return a < b
+	}
+
+	return a > b
+}
+
 type Statistic struct {
 	Id uint `gorm:"primaryKey"`
 
-	UserId uint `gorm:"not null"`
-	GameId uint `gorm:"not null"`
+	// A user or team has a single row per game in a club.
+	UserId uint `gorm:"not null;uniqueIndex:idx_statistics_standing"`
+	TeamId uint `gorm:"index;uniqueIndex:idx_statistics_standing"`
+	ClubId uint `gorm:"index;uniqueIndex:idx_statistics_standing"`
+	GameId uint `gorm:"not null;uniqueIndex:idx_statistics_standing"`
 
 	Wins   int
 	Draws  int
 	Losses int
 	Streak int
 
+	// Goals and sets are only counted in matches played in sets.
+	GoalsFor     int
+	GoalsAgainst int
+	SetsWon      int
+	SetsLost     int
+
+	// LongestWinStreak and LongestLossStreak are the longest runs of wins and losses ever, both counted as positive.
+	LongestWinStreak  int
+	LongestLossStreak int
+
+	LastPlayedAt *time.Time
+
 	CreatedAt time.Time
 }
+
+// Matches is the number of matches played.
+func (s Statistic) Matches() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// WinRate is the share of matches played that were won, or 0 without any matches.
+func (s Statistic) WinRate() float64 {
+	if s.Matches() == 0 {
+		return 0
+	}
+
+	return float64(s.Wins) / float64(s.Matches())
+}
+
+// GoalDifference is the goals scored minus the goals conceded.
+func (s Statistic) GoalDifference() int {
+	return s.GoalsFor - s.GoalsAgainst
+}
+
+// Value returns the value of the measure, by which statistics are ranked.
+func (s Statistic) Value(measure Measure) (float64, bool) {
+	switch measure {
+	case MeasureWins:
+		return float64(s.Wins), true
+	case MeasureStreak:
+		return float64(s.Streak), true
+	case MeasureGoalsFor:
+		return float64(s.GoalsFor), true
+	case MeasureGoalsAgainst:
+		return float64(s.GoalsAgainst), true
+	case MeasureGoalDifference:
+		return float64(s.GoalDifference()), true
+	case MeasureSetsWon:
+		return float64(s.SetsWon), true
+	case MeasureSetsLost:
+		return float64(s.SetsLost), true
+	case MeasureWinRate:
+		return s.WinRate(), true
+	case MeasureLongestWinStreak:
+		return float64(s.LongestWinStreak), true
+	case MeasureLongestLossStreak:
+		return float64(s.LongestLossStreak), true
+	default:
+		return 0, false
+	}
+}
+
+// Outcome is the result of a single match as it applies to statistics.
+// On a draw the winning and losing sides are simply the two sides of the match.
+// A free-for-all match has placements instead of sides, where a shared first place is a draw and anything below it a loss.
+type Outcome struct {
+	Draw          bool
+	WinnerIds     []uint
+	LoserIds      []uint
+	WinningTeamId uint
+	LosingTeamId  uint
+	Placements    map[uint]int
+
+	// WinnerGoals and LoserGoals are the goals each side scored over all sets, WinnerSets and LoserSets the sets each side won.
+	WinnerGoals int
+	LoserGoals  int
+	WinnerSets  int
+	LoserSets   int
+
+	PlayedAt time.Time
+}
+
+// UserIds returns everyone the outcome concerns.
+func (o Outcome) UserIds() []uint {
+	userIds := append(append([]uint{}, o.WinnerIds...), o.LoserIds...)
+	for userId := range o.Placements {
+		userIds = append(userIds, userId)
+	}
+
+	return userIds
+}
+
+// TeamIds returns the teams the outcome concerns.
+func (o Outcome) TeamIds() []uint {
+	var teamIds []uint
+	for _, teamId := range []uint{o.WinningTeamId, o.LosingTeamId} {
+		if teamId != 0 {
+			teamIds = append(teamIds, teamId)
+		}
+	}
+
+	return teamIds
+}
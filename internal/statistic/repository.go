@@ -2,16 +2,56 @@ package statistic
 
 import (
 	"context"
+	"matchlog/pkg/database"
 
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var (
+	ErrNotFound             = errors.New("not found")
+	ErrMeasureNotAggregated = errors.New("measure can not be combined across clubs")
+)
+
+// measureExpressions are what statistics are ranked by in SQL for each measure, matching Statistic.Value.
+var measureExpressions = map[Measure]string{
+	MeasureWins:              "wins",
+	MeasureStreak:            "streak",
+	MeasureGoalsFor:          "goals_for",
+	MeasureGoalsAgainst:      "goals_against",
+	MeasureGoalDifference:    "goals_for - goals_against",
+	MeasureSetsWon:           "sets_won",
+	MeasureSetsLost:          "sets_lost",
+	MeasureWinRate:           "COALESCE(wins / NULLIF(wins + draws + losses, 0), 0)",
+	MeasureLongestWinStreak:  "longest_win_streak",
+	MeasureLongestLossStreak: "longest_loss_streak",
+}
+
+// aggregateMeasureExpressions are what statistics of the same user in different clubs are ranked by in SQL.
+// A current streak only means something within one club, so it has no aggregate.
+var aggregateMeasureExpressions = map[Measure]string{
+	MeasureWins:              "SUM(wins)",
+	MeasureGoalsFor:          "SUM(goals_for)",
+	MeasureGoalsAgainst:      "SUM(goals_against)",
+	MeasureGoalDifference:    "SUM(goals_for) - SUM(goals_against)",
+	MeasureSetsWon:           "SUM(sets_won)",
+	MeasureSetsLost:          "SUM(sets_lost)",
+	MeasureWinRate:           "COALESCE(SUM(wins) / NULLIF(SUM(wins + draws + losses), 0), 0)",
+	MeasureLongestWinStreak:  "MAX(longest_win_streak)",
+	MeasureLongestLossStreak: "MAX(longest_loss_streak)",
+}
+
 type Repository interface {
-	GetStatisticsByUserIds(ctx context.Context, userIds []uint) ([]*Statistic, error)
-	GetStatisticByUserId(ctx context.Context, userId uint) (*Statistic, error)
-	GetTopXAmongUserIdsByWins(ctx context.Context, topX int, userIds []uint) (topXUserIds []uint, values []int, err error)
-	GetTopXAmongUserIdsByStreak(ctx context.Context, topX int, userIds []uint) (topXUserIds []uint, values []int, err error)
-	CreateStatistic(ctx context.Context, userId uint) error
+	GetStatisticsByUserIds(ctx context.Context, clubId, gameId uint, userIds []uint) ([]*Statistic, error)
+	GetStatisticByUserId(ctx context.Context, clubId, gameId uint, userId uint) (*Statistic, error)
+	GetAllStatisticsByUserId(ctx context.Context, userId uint) ([]Statistic, error)
+	GetStatisticsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]*Statistic, error)
+	GetStatisticsForUpdate(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) ([]*Statistic, error)
+	GetTopXAmongUserIdsByMeasure(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint, measure Measure) (topXUserIds []uint, values []float64, err error)
+	GetTopXAmongUserIdsByMeasureAcrossClubs(ctx context.Context, gameId uint, topX, minGames int, userIds []uint, measure Measure) (topXUserIds []uint, values []float64, err error)
+	GetAverageOfUserIdsByMeasurePerClub(ctx context.Context, gameId uint, minGames int, userIds []uint, measure Measure) (clubIds []uint, values []float64, players []int, err error)
+	CreateStatistics(ctx context.Context, stats []Statistic) error
 	UpdateStatistics(ctx context.Context, stats []Statistic) error
 }
 
@@ -25,10 +65,10 @@ func NewRepository(db *gorm.DB) Repository {
 	}
 }
 
-func (r *RepositoryImpl) GetStatisticsByUserIds(ctx context.Context, userIds []uint) ([]*Statistic, error) {
+func (r *RepositoryImpl) GetStatisticsByUserIds(ctx context.Context, clubId, gameId uint, userIds []uint) ([]*Statistic, error) {
 	var stats []*Statistic
-	result := r.db.WithContext(ctx).
-		Where("user_id IN ?", userIds).
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND user_id IN ?", clubId, gameId, userIds).
 		Find(&stats)
 	if result.Error != nil {
 		return nil, result.Error
@@ -37,66 +77,183 @@ func (r *RepositoryImpl) GetStatisticsByUserIds(ctx context.Context, userIds []u
 	return stats, nil
 }
 
-func (r *RepositoryImpl) GetStatisticByUserId(ctx context.Context, userId uint) (*Statistic, error) {
+func (r *RepositoryImpl) GetStatisticByUserId(ctx context.Context, clubId, gameId uint, userId uint) (*Statistic, error) {
 	var stats Statistic
-	result := r.db.WithContext(ctx).
-		Where("user_id = ?", userId).
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND user_id = ?", clubId, gameId, userId).
 		First(&stats)
 	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
 		return nil, result.Error
 	}
 
 	return &stats, nil
 }
 
-func (r *RepositoryImpl) GetTopXAmongUserIdsByWins(ctx context.Context, topX int, userIds []uint) ([]uint, []int, error) {
-	var topXUserIds []uint
-	var wins []int
+func (r *RepositoryImpl) GetAllStatisticsByUserId(ctx context.Context, userId uint) ([]Statistic, error) {
+	var stats []Statistic
+	result := database.Conn(ctx, r.db).
+		Where("user_id = ?", userId).
+		Find(&stats)
+	if result.Error != nil {
+		return nil, result.Error
+	}
 
-	result := r.db.
-		WithContext(ctx).
+	return stats, nil
+}
+
+func (r *RepositoryImpl) GetStatisticsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]*Statistic, error) {
+	var stats []*Statistic
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND game_id = ? AND team_id IN ?", clubId, gameId, teamIds).
+		Find(&stats)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return stats, nil
+}
+
+// GetStatisticsForUpdate locks the statistics of the users and teams until the surrounding transaction ends.
+// Rows are locked in order of id, so transactions locking some of the same rows can not deadlock on each other.
+func (r *RepositoryImpl) GetStatisticsForUpdate(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) ([]*Statistic, error) {
+	var stats []*Statistic
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("club_id = ? AND game_id = ?", clubId, gameId).
+		Where(r.db.Where("team_id = 0 AND user_id IN ?", userIds).Or("team_id IN ?", teamIds)).
+		Order("id asc").
+		Find(&stats)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return stats, nil
+}
+
+// GetTopXAmongUserIdsByMeasure ranks the users that played at least minGames matches by the measure,
+// breaking ties by the lowest user id the same way TopXAmongUserIdsByMeasure does.
+func (r *RepositoryImpl) GetTopXAmongUserIdsByMeasure(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint, measure Measure) ([]uint, []float64, error) {
+	expression, ok := measureExpressions[measure]
+	if !ok {
+		return nil, nil, errors.Errorf("unsupported measure: %s", measure)
+	}
+
+	var ranked []struct {
+		UserId uint
+		Value  float64
+	}
+	result := database.Conn(ctx, r.db).
 		Model(&Statistic{}).
-		Order("wins desc").
+		Select("user_id, "+expression+" AS value").
+		Where("club_id = ? AND game_id = ? AND user_id IN ?", clubId, gameId, userIds).
+		Where("wins + draws + losses >= ?", minGames).
+		Order(valueOrder(measure) + ", user_id asc").
 		Limit(topX).
-		Pluck("user_id", &topXUserIds).
-		Pluck("wins", &wins).
-		Where("user_id IN ?", userIds)
+		Scan(&ranked)
 	if result.Error != nil {
 		return nil, nil, result.Error
 	}
 
-	return topXUserIds, wins, nil
+	topXUserIds := make([]uint, len(ranked))
+	values := make([]float64, len(ranked))
+	for i, stat := range ranked {
+		topXUserIds[i] = stat.UserId
+		values[i] = stat.Value
+	}
+
+	return topXUserIds, values, nil
 }
 
-func (r *RepositoryImpl) GetTopXAmongUserIdsByStreak(ctx context.Context, topX int, userIds []uint) ([]uint, []int, error) {
-	var topXUserIds []uint
-	var streaks []int
+// GetTopXAmongUserIdsByMeasureAcrossClubs ranks the users by the measure over their statistics in every club,
+// leaving out users that played fewer than minGames matches in all clubs together.
+func (r *RepositoryImpl) GetTopXAmongUserIdsByMeasureAcrossClubs(ctx context.Context, gameId uint, topX, minGames int, userIds []uint, measure Measure) ([]uint, []float64, error) {
+	expression, ok := aggregateMeasureExpressions[measure]
+	if !ok {
+		return nil, nil, ErrMeasureNotAggregated
+	}
 
-	result := r.db.WithContext(ctx).
+	var ranked []struct {
+		UserId uint
+		Value  float64
+	}
+	result := database.Conn(ctx, r.db).
 		Model(&Statistic{}).
-		Order("streak desc").
+		Select("user_id, "+expression+" AS value").
+		Where("game_id = ? AND team_id = 0 AND user_id IN ?", gameId, userIds).
+		Group("user_id").
+		Having("SUM(wins + draws + losses) >= ?", minGames).
+		Order(valueOrder(measure) + ", user_id asc").
 		Limit(topX).
-		Where("user_id IN ?", userIds).
-		Pluck("user_id", &topXUserIds).
-		Pluck("streak", &streaks)
+		Scan(&ranked)
 	if result.Error != nil {
 		return nil, nil, result.Error
 	}
 
-	return topXUserIds, streaks, nil
+	topXUserIds := make([]uint, len(ranked))
+	values := make([]float64, len(ranked))
+	for i, stat := range ranked {
+		topXUserIds[i] = stat.UserId
+		values[i] = stat.Value
+	}
+
+	return topXUserIds, values, nil
+}
+
+// GetAverageOfUserIdsByMeasurePerClub averages the measure over the given users of each club that played at least minGames matches there,
+// best club first, along with how many players were averaged.
+func (r *RepositoryImpl) GetAverageOfUserIdsByMeasurePerClub(ctx context.Context, gameId uint, minGames int, userIds []uint, measure Measure) ([]uint, []float64, []int, error) {
+	expression, ok := measureExpressions[measure]
+	if !ok {
+		return nil, nil, nil, errors.Errorf("unsupported measure: %s", measure)
+	}
+
+	var ranked []struct {
+		ClubId  uint
+		Value   float64
+		Players int
+	}
+	result := database.Conn(ctx, r.db).
+		Model(&Statistic{}).
+		Select("club_id, AVG("+expression+") AS value, COUNT(*) AS players").
+		Where("game_id = ? AND team_id = 0 AND user_id IN ?", gameId, userIds).
+		Where("wins + draws + losses >= ?", minGames).
+		Group("club_id").
+		Order(valueOrder(measure) + ", club_id asc").
+		Scan(&ranked)
+	if result.Error != nil {
+		return nil, nil, nil, result.Error
+	}
+
+	clubIds := make([]uint, len(ranked))
+	values := make([]float64, len(ranked))
+	players := make([]int, len(ranked))
+	for i, club := range ranked {
+		clubIds[i] = club.ClubId
+		values[i] = club.Value
+		players[i] = club.Players
+	}
+
+	return clubIds, values, players, nil
 }
 
-func (r *RepositoryImpl) CreateStatistic(ctx context.Context, userId uint) error {
-	stat := Statistic{
-		UserId: userId,
-		Wins:   0,
-		Draws:  0,
-		Losses: 0,
-		Streak: 0,
+// valueOrder orders values of the measure best first.
+func valueOrder(measure Measure) string {
+	if measure.LowerIsBetter() {
+		return "value asc"
 	}
 
-	result := r.db.WithContext(ctx).
-		Create(&stat)
+	return "value desc"
+}
+
+// CreateStatistics skips statistics created in the meantime by a match confirmed at the same time.
+func (r *RepositoryImpl) CreateStatistics(ctx context.Context, stats []Statistic) error {
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&stats)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -105,10 +262,11 @@ func (r *RepositoryImpl) CreateStatistic(ctx context.Context, userId uint) error
 }
 
 func (r *RepositoryImpl) UpdateStatistics(ctx context.Context, stats []Statistic) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
 		for _, stat := range stats {
 			result := tx.WithContext(ctx).
 				Model(&stat).
+				Select("*").
 				Updates(stat)
 			if result.Error != nil {
 				return result.Error
@@ -2,16 +2,21 @@ package statistic
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type Service interface {
-	GetStatisticByUserId(ctx context.Context, userId uint) (*Statistic, error)
-	GetTopXAmongUserIdsByMeasure(ctx context.Context, topX int, userIds []uint, measure Measure) (topXUserIds []uint, values []int, err error)
-	CreateStatistic(ctx context.Context, userId uint) error
-	UpdateStatisticsByUserIds(ctx context.Context, userIds []uint, result MatchResult) error
+	GetStatisticByUserId(ctx context.Context, clubId, gameId uint, userId uint) (*Statistic, error)
+	GetStatisticsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]*Statistic, error)
+	GetTopXAmongUserIdsByMeasure(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint, measure Measure) (topXUserIds []uint, values []float64, err error)
+	GetTopXAmongUserIdsByMeasureAcrossClubs(ctx context.Context, gameId uint, topX, minGames int, userIds []uint, measure Measure) (topXUserIds []uint, values []float64, err error)
+	GetAverageOfUserIdsByMeasurePerClub(ctx context.Context, gameId uint, minGames int, userIds []uint, measure Measure) (clubIds []uint, values []float64, players []int, err error)
+	EnsureStatistics(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) error
+	ApplyOutcome(ctx context.Context, clubId, gameId uint, outcome Outcome) error
 	TransferStatistics(ctx context.Context, fromUserId, toUserId uint) error
+	RecalculateStatistics(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint, outcomes []Outcome) error
 }
 
 type ServiceImpl struct {
@@ -24,8 +29,8 @@ func NewService(repo Repository) Service {
 	}
 }
 
-func (s *ServiceImpl) GetStatisticByUserId(ctx context.Context, userId uint) (*Statistic, error) {
-	stats, err := s.repo.GetStatisticByUserId(ctx, userId)
+func (s *ServiceImpl) GetStatisticByUserId(ctx context.Context, clubId, gameId uint, userId uint) (*Statistic, error) {
+	stats, err := s.repo.GetStatisticByUserId(ctx, clubId, gameId, userId)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get statistics for user %d", userId)
 	}
@@ -33,73 +38,117 @@ func (s *ServiceImpl) GetStatisticByUserId(ctx context.Context, userId uint) (*S
 	return stats, nil
 }
 
-func (s *ServiceImpl) GetTopXAmongUserIdsByMeasure(ctx context.Context, topX int, userIds []uint, measure Measure) ([]uint, []int, error) {
-	var topXUserIds []uint
-	var values []int
+func (s *ServiceImpl) GetStatisticsByTeamIds(ctx context.Context, clubId, gameId uint, teamIds []uint) ([]*Statistic, error) {
+	stats, err := s.repo.GetStatisticsByTeamIds(ctx, clubId, gameId, teamIds)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get statistics for teams %v", teamIds)
+	}
 
-	switch measure {
-	case MeasureWins:
-		ids, wins, err := s.repo.GetTopXAmongUserIdsByWins(ctx, topX, userIds)
-		if err != nil {
-			return nil, nil, errors.Wrapf(err, "failed to get top %d users by wins", topX)
-		}
+	return stats, nil
+}
 
-		topXUserIds = ids
-		values = wins
-	case MeasureStreak:
-		ids, streaks, err := s.repo.GetTopXAmongUserIdsByStreak(ctx, topX, userIds)
-		if err != nil {
-			return nil, nil, errors.Wrapf(err, "failed to get top %d users by win streaks", topX)
-		}
+func (s *ServiceImpl) GetTopXAmongUserIdsByMeasure(ctx context.Context, clubId, gameId uint, topX, minGames int, userIds []uint, measure Measure) ([]uint, []float64, error) {
+	topXUserIds, values, err := s.repo.GetTopXAmongUserIdsByMeasure(ctx, clubId, gameId, topX, minGames, userIds, measure)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get top %d users by %s", topX, measure)
+	}
 
-		topXUserIds = ids
-		values = streaks
-	default:
-		return nil, nil, errors.Errorf("unsupported measure: %s", measure)
+	return topXUserIds, values, nil
+}
+
+func (s *ServiceImpl) GetTopXAmongUserIdsByMeasureAcrossClubs(ctx context.Context, gameId uint, topX, minGames int, userIds []uint, measure Measure) ([]uint, []float64, error) {
+	topXUserIds, values, err := s.repo.GetTopXAmongUserIdsByMeasureAcrossClubs(ctx, gameId, topX, minGames, userIds, measure)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get top %d users by %s across clubs", topX, measure)
 	}
 
 	return topXUserIds, values, nil
 }
 
-func (s *ServiceImpl) CreateStatistic(ctx context.Context, userId uint) error {
-	if err := s.repo.CreateStatistic(ctx, userId); err != nil {
-		return errors.Wrapf(err, "failed to create statistics for user %d", userId)
+func (s *ServiceImpl) GetAverageOfUserIdsByMeasurePerClub(ctx context.Context, gameId uint, minGames int, userIds []uint, measure Measure) ([]uint, []float64, []int, error) {
+	clubIds, values, players, err := s.repo.GetAverageOfUserIdsByMeasurePerClub(ctx, gameId, minGames, userIds, measure)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to get average %s per club", measure)
 	}
 
-	return nil
+	return clubIds, values, players, nil
 }
 
-func (s *ServiceImpl) UpdateStatisticsByUserIds(ctx context.Context, userIds []uint, result MatchResult) error {
-	oldStatistics, err := s.repo.GetStatisticsByUserIds(ctx, userIds)
+// EnsureStatistics creates empty statistics for the users and teams that have not played the game in the club before.
+func (s *ServiceImpl) EnsureStatistics(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) error {
+	userStats, err := s.repo.GetStatisticsByUserIds(ctx, clubId, gameId, userIds)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get statistics for users %v", userIds)
 	}
 
-	updatedStatistics := make([]Statistic, len(userIds))
-	for i := range userIds {
-		stats := oldStatistics[i]
+	teamStats, err := s.repo.GetStatisticsByTeamIds(ctx, clubId, gameId, teamIds)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get statistics for teams %v", teamIds)
+	}
+
+	hasUser := make(map[uint]bool, len(userStats))
+	for _, stats := range userStats {
+		hasUser[stats.UserId] = true
+	}
 
-		switch result {
-		case ResultWin:
-			stats.Wins++
-			if stats.Streak >= 0 {
-				stats.Streak++
-			} else {
-				stats.Streak = 1
-			}
-		case ResultLoss:
-			stats.Losses++
-			if stats.Streak <= 0 {
-				stats.Streak--
-			} else {
-				stats.Streak = -1
-			}
-		case ResultDraw:
-			stats.Draws++
-			stats.Streak = 0
+	hasTeam := make(map[uint]bool, len(teamStats))
+	for _, stats := range teamStats {
+		hasTeam[stats.TeamId] = true
+	}
+
+	var missing []Statistic
+	for _, userId := range userIds {
+		if !hasUser[userId] {
+			hasUser[userId] = true
+			missing = append(missing, Statistic{UserId: userId, ClubId: clubId, GameId: gameId})
 		}
+	}
 
-		updatedStatistics[i] = *stats
+	for _, teamId := range teamIds {
+		if !hasTeam[teamId] {
+			hasTeam[teamId] = true
+			missing = append(missing, Statistic{TeamId: teamId, ClubId: clubId, GameId: gameId})
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := s.repo.CreateStatistics(ctx, missing); err != nil {
+		return errors.Wrap(err, "failed to create statistics")
+	}
+
+	return nil
+}
+
+// ApplyOutcome applies the outcome of a match to the statistics of the players and teams it concerns.
+// The statistics are locked while they are changed, so matches confirmed at the same time do not overwrite each other.
+func (s *ServiceImpl) ApplyOutcome(ctx context.Context, clubId, gameId uint, outcome Outcome) error {
+	userStats, teamStats, err := s.getStatisticsForUpdate(ctx, clubId, gameId, outcome.UserIds(), outcome.TeamIds())
+	if err != nil {
+		return err
+	}
+
+	statsByUserId := make(map[uint]*Statistic, len(userStats))
+	for _, stats := range userStats {
+		statsByUserId[stats.UserId] = stats
+	}
+
+	statsByTeamId := make(map[uint]*Statistic, len(teamStats))
+	for _, stats := range teamStats {
+		statsByTeamId[stats.TeamId] = stats
+	}
+
+	applyOutcome(statsByUserId, statsByTeamId, outcome)
+
+	updatedStatistics := make([]Statistic, 0, len(userStats)+len(teamStats))
+	for _, stats := range userStats {
+		updatedStatistics = append(updatedStatistics, *stats)
+	}
+
+	for _, stats := range teamStats {
+		updatedStatistics = append(updatedStatistics, *stats)
 	}
 
 	if err := s.repo.UpdateStatistics(ctx, updatedStatistics); err != nil {
@@ -109,23 +158,223 @@ func (s *ServiceImpl) UpdateStatisticsByUserIds(ctx context.Context, userIds []u
 	return nil
 }
 
+// getStatisticsForUpdate locks the statistics of the users and teams, and returns those of the users apart from those of the teams.
+func (s *ServiceImpl) getStatisticsForUpdate(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint) ([]*Statistic, []*Statistic, error) {
+	stats, err := s.repo.GetStatisticsForUpdate(ctx, clubId, gameId, userIds, teamIds)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get statistics for users %v and teams %v", userIds, teamIds)
+	}
+
+	var userStats, teamStats []*Statistic
+	for _, stat := range stats {
+		if stat.TeamId != 0 {
+			teamStats = append(teamStats, stat)
+		} else {
+			userStats = append(userStats, stat)
+		}
+	}
+
+	return userStats, teamStats, nil
+}
+
+// TransferStatistics swaps the statistics of two users in every club and game.
 func (s *ServiceImpl) TransferStatistics(ctx context.Context, fromUserId, toUserId uint) error {
-	fromStats, err := s.repo.GetStatisticByUserId(ctx, fromUserId)
+	fromStats, err := s.repo.GetAllStatisticsByUserId(ctx, fromUserId)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get statistics for user %d", fromUserId)
 	}
 
-	toStats, err := s.repo.GetStatisticByUserId(ctx, toUserId)
+	toStats, err := s.repo.GetAllStatisticsByUserId(ctx, toUserId)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get statistics for user %d", toUserId)
 	}
 
-	fromStats.UserId = toUserId
-	toStats.UserId = fromUserId
+	transferedStats := make([]Statistic, 0, len(fromStats)+len(toStats))
+	for _, stats := range fromStats {
+		stats.UserId = toUserId
+		transferedStats = append(transferedStats, stats)
+	}
+
+	for _, stats := range toStats {
+		stats.UserId = fromUserId
+		transferedStats = append(transferedStats, stats)
+	}
 
-	if err := s.repo.UpdateStatistics(ctx, []Statistic{*fromStats, *toStats}); err != nil {
+	if err := s.repo.UpdateStatistics(ctx, transferedStats); err != nil {
 		return errors.Wrap(err, "failed to update statistics")
 	}
 
 	return nil
 }
+
+// RecalculateStatistics resets the statistics of the given users and teams and replays the outcomes in order.
+func (s *ServiceImpl) RecalculateStatistics(ctx context.Context, clubId, gameId uint, userIds, teamIds []uint, outcomes []Outcome) error {
+	userStats, teamStats, err := s.getStatisticsForUpdate(ctx, clubId, gameId, userIds, teamIds)
+	if err != nil {
+		return err
+	}
+
+	statsByUserId := make(map[uint]*Statistic, len(userStats))
+	for _, stats := range userStats {
+		resetStatistic(stats)
+		statsByUserId[stats.UserId] = stats
+	}
+
+	statsByTeamId := make(map[uint]*Statistic, len(teamStats))
+	for _, stats := range teamStats {
+		resetStatistic(stats)
+		statsByTeamId[stats.TeamId] = stats
+	}
+
+	for _, outcome := range outcomes {
+		applyOutcome(statsByUserId, statsByTeamId, outcome)
+	}
+
+	updatedStatistics := make([]Statistic, 0, len(userStats)+len(teamStats))
+	for _, stats := range userStats {
+		updatedStatistics = append(updatedStatistics, *stats)
+	}
+
+	for _, stats := range teamStats {
+		updatedStatistics = append(updatedStatistics, *stats)
+	}
+
+	if err := s.repo.UpdateStatistics(ctx, updatedStatistics); err != nil {
+		return errors.Wrap(err, "failed to update recalculated statistics")
+	}
+
+	return nil
+}
+
+func resetStatistic(stats *Statistic) {
+	stats.Wins = 0
+	stats.Draws = 0
+	stats.Losses = 0
+	stats.Streak = 0
+	stats.GoalsFor = 0
+	stats.GoalsAgainst = 0
+	stats.SetsWon = 0
+	stats.SetsLost = 0
+	stats.LongestWinStreak = 0
+	stats.LongestLossStreak = 0
+	stats.LastPlayedAt = nil
+}
+
+// applyOutcome applies an outcome to the given statistics, skipping anyone without statistics.
+func applyOutcome(statsByUserId, statsByTeamId map[uint]*Statistic, outcome Outcome) {
+	if len(outcome.Placements) > 0 {
+		for userId, result := range placementResults(outcome.Placements) {
+			if stats, ok := statsByUserId[userId]; ok {
+				applyResult(stats, result, outcome.PlayedAt)
+			}
+		}
+
+		return
+	}
+
+	winnerResult, loserResult := ResultWin, ResultLoss
+	if outcome.Draw {
+		winnerResult, loserResult = ResultDraw, ResultDraw
+	}
+
+	var winnerStats, loserStats []*Statistic
+	for _, userId := range outcome.WinnerIds {
+		if stats, ok := statsByUserId[userId]; ok {
+			winnerStats = append(winnerStats, stats)
+		}
+	}
+
+	for _, userId := range outcome.LoserIds {
+		if stats, ok := statsByUserId[userId]; ok {
+			loserStats = append(loserStats, stats)
+		}
+	}
+
+	if stats, ok := statsByTeamId[outcome.WinningTeamId]; ok {
+		winnerStats = append(winnerStats, stats)
+	}
+
+	if stats, ok := statsByTeamId[outcome.LosingTeamId]; ok {
+		loserStats = append(loserStats, stats)
+	}
+
+	for _, stats := range winnerStats {
+		applyResult(stats, winnerResult, outcome.PlayedAt)
+		applyScore(stats, outcome.WinnerGoals, outcome.LoserGoals, outcome.WinnerSets, outcome.LoserSets)
+	}
+
+	for _, stats := range loserStats {
+		applyResult(stats, loserResult, outcome.PlayedAt)
+		applyScore(stats, outcome.LoserGoals, outcome.WinnerGoals, outcome.LoserSets, outcome.WinnerSets)
+	}
+}
+
+// placementResults turns placements into results, where a sole first place is a win,
+// a shared first place a draw, and anything below it a loss.
+func placementResults(placements map[uint]int) map[uint]MatchResult {
+	best, sharedBy := 0, 0
+	for _, placement := range placements {
+		switch {
+		case sharedBy == 0 || placement < best:
+			best, sharedBy = placement, 1
+		case placement == best:
+			sharedBy++
+		}
+	}
+
+	results := make(map[uint]MatchResult, len(placements))
+	for userId, placement := range placements {
+		switch {
+		case placement != best:
+			results[userId] = ResultLoss
+		case sharedBy > 1:
+			results[userId] = ResultDraw
+		default:
+			results[userId] = ResultWin
+		}
+	}
+
+	return results
+}
+
+func applyResult(stats *Statistic, result MatchResult, playedAt time.Time) {
+	switch result {
+	case ResultWin:
+		stats.Wins++
+		if stats.Streak >= 0 {
+			stats.Streak++
+		} else {
+			stats.Streak = 1
+		}
+
+		if stats.Streak > stats.LongestWinStreak {
+			stats.LongestWinStreak = stats.Streak
+		}
+	case ResultLoss:
+		stats.Losses++
+		if stats.Streak <= 0 {
+			stats.Streak--
+		} else {
+			stats.Streak = -1
+		}
+
+		if -stats.Streak > stats.LongestLossStreak {
+			stats.LongestLossStreak = -stats.Streak
+		}
+	case ResultDraw:
+		stats.Draws++
+		stats.Streak = 0
+	}
+
+	// Matches are not necessarily applied in the order they were played, as they are applied once confirmed.
+	if !playedAt.IsZero() && (stats.LastPlayedAt == nil || playedAt.After(*stats.LastPlayedAt)) {
+		stats.LastPlayedAt = &playedAt
+	}
+}
+
+func applyScore(stats *Statistic, goalsFor, goalsAgainst, setsWon, setsLost int) {
+	stats.GoalsFor += goalsFor
+	stats.GoalsAgainst += goalsAgainst
+	stats.SetsWon += setsWon
+	stats.SetsLost += setsLost
+}
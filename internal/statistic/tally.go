@@ -0,0 +1,150 @@
+package statistic
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Period is a named window of time that statistics can be computed over.
+type Period string
+
+const (
+	PeriodWeek    Period = "week"
+	PeriodMonth   Period = "month"
+	PeriodQuarter Period = "quarter"
+	PeriodYear    Period = "year"
+)
+
+// Window returns the start of the period that now falls in, and the start of the next one.
+// Weeks start on Monday.
+func (p Period) Window(now time.Time) (from, to time.Time, err error) {
+	year, month, day := now.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	switch p {
+	case PeriodWeek:
+		from = midnight.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+		return from, from.AddDate(0, 0, 7), nil
+	case PeriodMonth:
+		from = time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		return from, from.AddDate(0, 1, 0), nil
+	case PeriodQuarter:
+		from = time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, now.Location())
+		return from, from.AddDate(0, 3, 0), nil
+	case PeriodYear:
+		from = time.Date(year, time.January, 1, 0, 0, 0, 0, now.Location())
+		return from, from.AddDate(1, 0, 0), nil
+	default:
+		return time.Time{}, time.Time{}, errors.Errorf("unknown period: %s", p)
+	}
+}
+
+// Tally computes statistics from scratch by applying the outcomes in order,
+// for every player and team taking part in them.
+func Tally(clubId, gameId uint, outcomes []Outcome) []Statistic {
+	statsByUserId, statsByTeamId := tally(clubId, gameId, outcomes, nil)
+
+	stats := make([]Statistic, 0, len(statsByUserId)+len(statsByTeamId))
+	for _, stat := range statsByUserId {
+		stats = append(stats, *stat)
+	}
+
+	for _, stat := range statsByTeamId {
+		stats = append(stats, *stat)
+	}
+
+	return stats
+}
+
+// TallyEach computes statistics from scratch like Tally, returning for every outcome
+// the statistics of its players as they were right after it was applied.
+func TallyEach(clubId, gameId uint, outcomes []Outcome) []map[uint]Statistic {
+	statsAfter := make([]map[uint]Statistic, 0, len(outcomes))
+	tally(clubId, gameId, outcomes, func(outcome Outcome, statsByUserId map[uint]*Statistic) {
+		stats := make(map[uint]Statistic, len(outcome.UserIds()))
+		for _, userId := range outcome.UserIds() {
+			stats[userId] = *statsByUserId[userId]
+		}
+
+		statsAfter = append(statsAfter, stats)
+	})
+
+	return statsAfter
+}
+
+// tally applies the outcomes in order to fresh statistics of everyone taking part in them,
+// calling applied, if given, after each of them.
+func tally(clubId, gameId uint, outcomes []Outcome, applied func(outcome Outcome, statsByUserId map[uint]*Statistic)) (map[uint]*Statistic, map[uint]*Statistic) {
+	statsByUserId := map[uint]*Statistic{}
+	statsByTeamId := map[uint]*Statistic{}
+
+	for _, outcome := range outcomes {
+		for _, userId := range outcome.UserIds() {
+			if _, ok := statsByUserId[userId]; !ok {
+				statsByUserId[userId] = &Statistic{UserId: userId, ClubId: clubId, GameId: gameId}
+			}
+		}
+
+		for _, teamId := range outcome.TeamIds() {
+			if _, ok := statsByTeamId[teamId]; !ok {
+				statsByTeamId[teamId] = &Statistic{TeamId: teamId, ClubId: clubId, GameId: gameId}
+			}
+		}
+
+		applyOutcome(statsByUserId, statsByTeamId, outcome)
+
+		if applied != nil {
+			applied(outcome, statsByUserId)
+		}
+	}
+
+	return statsByUserId, statsByTeamId
+}
+
+// TopXAmongUserIdsByMeasure ranks the statistics of the given users by the measure, the same way the stored statistics are ranked.
+// Users without statistics, or with fewer than minGames matches, are left out.
+func TopXAmongUserIdsByMeasure(stats []Statistic, topX, minGames int, userIds []uint, measure Measure) ([]uint, []float64, error) {
+	if _, ok := (Statistic{}).Value(measure); !ok {
+		return nil, nil, errors.Errorf("unsupported measure: %s", measure)
+	}
+
+	among := make(map[uint]bool, len(userIds))
+	for _, userId := range userIds {
+		among[userId] = true
+	}
+
+	var ranked []Statistic
+	for _, stat := range stats {
+		if stat.TeamId == 0 && among[stat.UserId] && stat.Matches() >= minGames {
+			ranked = append(ranked, stat)
+		}
+	}
+
+	value := func(stat Statistic) float64 {
+		v, _ := stat.Value(measure)
+		return v
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if value(ranked[i]) != value(ranked[j]) {
+			return measure.Better(value(ranked[i]), value(ranked[j]))
+		}
+
+		return ranked[i].UserId < ranked[j].UserId
+	})
+
+	if len(ranked) > topX {
+		ranked = ranked[:topX]
+	}
+
+	topXUserIds := make([]uint, len(ranked))
+	values := make([]float64, len(ranked))
+	for i, stat := range ranked {
+		topXUserIds[i] = stat.UserId
+		values[i] = value(stat)
+	}
+
+	return topXUserIds, values, nil
+}
@@ -0,0 +1,15 @@
+package team
+
+import (
+	"time"
+)
+
+type Team struct {
+	Id uint `gorm:"primaryKey"`
+
+	ClubId  uint   `gorm:"not null;uniqueIndex:idx_teams_club_members"`
+	Members string `gorm:"not null;size:255;uniqueIndex:idx_teams_club_members"`
+	UserIds []uint `gorm:"serializer:json;not null"`
+
+	CreatedAt time.Time
+}
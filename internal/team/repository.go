@@ -0,0 +1,126 @@
+package team
+
+import (
+	"context"
+	"matchlog/pkg/database"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const ErrCodeMySQLDuplicateEntry uint16 = 1062
+
+var (
+	ErrDuplicateEntry = errors.New("already exists")
+	ErrNotFound       = errors.New("not found")
+)
+
+type Repository interface {
+	GetTeam(ctx context.Context, id uint) (*Team, error)
+	GetTeams(ctx context.Context, ids []uint) ([]Team, error)
+	GetTeamsInClub(ctx context.Context, clubId uint) ([]Team, error)
+	GetTeamByMembers(ctx context.Context, clubId uint, members string) (*Team, error)
+	GetTeamByMembersForUpdate(ctx context.Context, clubId uint, members string) (*Team, error)
+	CreateTeam(ctx context.Context, team *Team) error
+}
+
+type RepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &RepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *RepositoryImpl) GetTeam(ctx context.Context, id uint) (*Team, error) {
+	var team Team
+	result := database.Conn(ctx, r.db).
+		First(&team, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &team, nil
+}
+
+func (r *RepositoryImpl) GetTeams(ctx context.Context, ids []uint) ([]Team, error) {
+	var teams []Team
+	result := database.Conn(ctx, r.db).
+		Where("id IN ?", ids).
+		Find(&teams)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return teams, nil
+}
+
+func (r *RepositoryImpl) GetTeamsInClub(ctx context.Context, clubId uint) ([]Team, error) {
+	var teams []Team
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ?", clubId).
+		Find(&teams)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return teams, nil
+}
+
+func (r *RepositoryImpl) GetTeamByMembers(ctx context.Context, clubId uint, members string) (*Team, error) {
+	var team Team
+	result := database.Conn(ctx, r.db).
+		Where("club_id = ? AND members = ?", clubId, members).
+		First(&team)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &team, nil
+}
+
+// GetTeamByMembersForUpdate reads the team with a lock, which unlike a plain read inside a transaction
+// also finds a team created by another transaction since this one started.
+func (r *RepositoryImpl) GetTeamByMembersForUpdate(ctx context.Context, clubId uint, members string) (*Team, error) {
+	var team Team
+	result := database.Conn(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("club_id = ? AND members = ?", clubId, members).
+		First(&team)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, result.Error
+	}
+
+	return &team, nil
+}
+
+func (r *RepositoryImpl) CreateTeam(ctx context.Context, team *Team) error {
+	result := database.Conn(ctx, r.db).
+		Create(team)
+	if result.Error != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(result.Error, &mysqlErr) && mysqlErr.Number == ErrCodeMySQLDuplicateEntry {
+			return ErrDuplicateEntry
+		}
+
+		return result.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package team
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Service interface {
+	GetTeam(ctx context.Context, id uint) (*Team, error)
+	GetTeams(ctx context.Context, ids []uint) ([]Team, error)
+	GetTeamsInClub(ctx context.Context, clubId uint) ([]Team, error)
+	GetOrCreateTeam(ctx context.Context, clubId uint, userIds []uint) (team *Team, created bool, err error)
+}
+
+type ServiceImpl struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &ServiceImpl{
+		repo: repo,
+	}
+}
+
+func (s *ServiceImpl) GetTeam(ctx context.Context, id uint) (*Team, error) {
+	team, err := s.repo.GetTeam(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get team %d", id)
+	}
+
+	return team, nil
+}
+
+func (s *ServiceImpl) GetTeams(ctx context.Context, ids []uint) ([]Team, error) {
+	teams, err := s.repo.GetTeams(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get teams %v", ids)
+	}
+
+	return teams, nil
+}
+
+func (s *ServiceImpl) GetTeamsInClub(ctx context.Context, clubId uint) ([]Team, error) {
+	teams, err := s.repo.GetTeamsInClub(ctx, clubId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get teams in club %d", clubId)
+	}
+
+	return teams, nil
+}
+
+func (s *ServiceImpl) GetOrCreateTeam(ctx context.Context, clubId uint, userIds []uint) (*Team, bool, error) {
+	sortedIds := make([]uint, len(userIds))
+	copy(sortedIds, userIds)
+	sort.Slice(sortedIds, func(i, j int) bool { return sortedIds[i] < sortedIds[j] })
+
+	members := membersKey(sortedIds)
+
+	team, err := s.repo.GetTeamByMembers(ctx, clubId, members)
+	if err == nil {
+		return team, false, nil
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		return nil, false, errors.Wrapf(err, "failed to get team with members %s", members)
+	}
+
+	team = &Team{
+		ClubId:  clubId,
+		Members: members,
+		UserIds: sortedIds,
+	}
+
+	err = s.repo.CreateTeam(ctx, team)
+	// A match with the same players recorded at the same time got there first.
+	if errors.Is(err, ErrDuplicateEntry) {
+		team, err := s.repo.GetTeamByMembersForUpdate(ctx, clubId, members)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to get team with members %s", members)
+		}
+
+		return team, false, nil
+	}
+
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to create team with members %s", members)
+	}
+
+	return team, true, nil
+}
+
+// membersKey returns a canonical representation of a set of user ids,
+// so the same players are always resolved to the same team.
+func membersKey(sortedIds []uint) string {
+	ids := make([]string, len(sortedIds))
+	for i, id := range sortedIds {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+
+	return strings.Join(ids, "-")
+}
@@ -1,6 +1,9 @@
 package tournament
 
-import "time"
+import (
+	"matchlog/internal/team"
+	"time"
+)
 
 type Tournament struct {
 	Id uint `gorm:"primaryKey"`
@@ -17,8 +20,9 @@ type Tournament struct {
 type TournamentTeam struct {
 	Id uint `gorm:"primaryKey"`
 
-	TournamentID uint `gorm:"not null"`
-	TeamID       uint `gorm:"not null"`
+	TournamentID uint       `gorm:"not null"`
+	TeamID       uint       `gorm:"not null"`
+	Team         *team.Team `gorm:"foreignKey:TeamID"`
 
 	InitialSeed    uint
 	FinalPlacement uint
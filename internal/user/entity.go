@@ -12,5 +12,8 @@ type User struct {
 	Hash    string
 	Virtual bool `gorm:"default:false"`
 
+	// ShowOnGlobalLeaderboards is opted into, users are only ranked against people outside their clubs if they choose to be.
+	ShowOnGlobalLeaderboards bool `gorm:"not null;default:false"`
+
 	CreatedAt time.Time
 }
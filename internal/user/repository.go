@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"matchlog/pkg/database"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
@@ -24,6 +25,8 @@ type Repository interface {
 	CreateUser(ctx context.Context, user *User) error
 	DeleteUser(ctx context.Context, id uint) error
 	UpdateUser(ctx context.Context, user *User) error
+	GetUserIdsShownOnGlobalLeaderboards(ctx context.Context) ([]uint, error)
+	SetShowOnGlobalLeaderboards(ctx context.Context, id uint, show bool) error
 }
 
 type RepositoryImpl struct {
@@ -38,7 +41,7 @@ func NewRepository(db *gorm.DB) Repository {
 
 func (r *RepositoryImpl) GetUser(ctx context.Context, id uint) (*User, error) {
 	var user *User
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Where("id = ?", id).
 		First(&user)
 	if result.Error != nil {
@@ -50,7 +53,7 @@ func (r *RepositoryImpl) GetUser(ctx context.Context, id uint) (*User, error) {
 
 func (r *RepositoryImpl) GetUsers(ctx context.Context, ids []uint) ([]*User, error) {
 	var users []*User
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Where("id IN ?", ids).
 		Find(&users)
 	if result.Error != nil {
@@ -62,7 +65,7 @@ func (r *RepositoryImpl) GetUsers(ctx context.Context, ids []uint) ([]*User, err
 
 func (r *RepositoryImpl) GetUsersInClub(ctx context.Context, clubId uint) ([]User, error) {
 	var users []User
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Where("club_id = ?", clubId).
 		Find(&users)
 	if result.Error != nil {
@@ -73,7 +76,7 @@ func (r *RepositoryImpl) GetUsersInClub(ctx context.Context, clubId uint) ([]Use
 }
 
 func (r *RepositoryImpl) CreateUser(ctx context.Context, user *User) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Create(&user)
 	if result.Error != nil {
 		var mysqlErr *mysql.MySQLError
@@ -89,7 +92,7 @@ func (r *RepositoryImpl) CreateUser(ctx context.Context, user *User) error {
 
 func (r *RepositoryImpl) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	var user User
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Where("email = ?", email).
 		First(&user)
 	if result.Error != nil {
@@ -105,7 +108,7 @@ func (r *RepositoryImpl) GetUserByEmail(ctx context.Context, email string) (*Use
 
 func (r *RepositoryImpl) GetUsersByEmails(ctx context.Context, emails []string) ([]*User, error) {
 	var users []*User
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Where("email IN ?", emails).
 		Find(&users)
 	if result.Error != nil {
@@ -116,7 +119,7 @@ func (r *RepositoryImpl) GetUsersByEmails(ctx context.Context, emails []string)
 }
 
 func (r *RepositoryImpl) DeleteUser(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Where("id = ?", id).
 		Delete(&User{})
 	if result.Error != nil {
@@ -127,7 +130,7 @@ func (r *RepositoryImpl) DeleteUser(ctx context.Context, id uint) error {
 }
 
 func (r *RepositoryImpl) UpdateUser(ctx context.Context, user *User) error {
-	result := r.db.WithContext(ctx).
+	result := database.Conn(ctx, r.db).
 		Model(&User{}).
 		Where("id = ?", user.Id).
 		Updates(user)
@@ -137,3 +140,29 @@ func (r *RepositoryImpl) UpdateUser(ctx context.Context, user *User) error {
 
 	return nil
 }
+
+func (r *RepositoryImpl) GetUserIdsShownOnGlobalLeaderboards(ctx context.Context) ([]uint, error) {
+	var userIds []uint
+	result := database.Conn(ctx, r.db).
+		Model(&User{}).
+		Where("show_on_global_leaderboards = ?", true).
+		Pluck("id", &userIds)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return userIds, nil
+}
+
+// SetShowOnGlobalLeaderboards is separate from UpdateUser, which leaves out fields with the zero value and so could never opt out.
+func (r *RepositoryImpl) SetShowOnGlobalLeaderboards(ctx context.Context, id uint, show bool) error {
+	result := database.Conn(ctx, r.db).
+		Model(&User{}).
+		Where("id = ?", id).
+		Update("show_on_global_leaderboards", show)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
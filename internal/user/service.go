@@ -12,9 +12,11 @@ type Service interface {
 	GetUserByEmail(ctx context.Context, email string) (exists bool, user *User, err error)
 	GetUsersByEmails(ctx context.Context, emails []string) ([]*User, error)
 	CreateUser(ctx context.Context, email, name, hash string) error
-	CreateVirtualUser(ctx context.Context, name string) error
+	CreateVirtualUser(ctx context.Context, name string) (userId uint, err error)
 	DeleteUser(ctx context.Context, id uint) error
 	UpdateUser(ctx context.Context, id uint, email, name, hash string, virtual bool) error
+	GetUserIdsShownOnGlobalLeaderboards(ctx context.Context) ([]uint, error)
+	SetShowOnGlobalLeaderboards(ctx context.Context, id uint, show bool) error
 }
 
 type ServiceImpl struct {
@@ -60,17 +62,17 @@ func (s *ServiceImpl) CreateUser(ctx context.Context, email, name, hash string)
 	return nil
 }
 
-func (s *ServiceImpl) CreateVirtualUser(ctx context.Context, name string) error {
+func (s *ServiceImpl) CreateVirtualUser(ctx context.Context, name string) (uint, error) {
 	user := &User{
 		Name:    name,
 		Virtual: true,
 	}
 
 	if err := s.repo.CreateUser(ctx, user); err != nil {
-		return errors.Wrap(err, "failed to create virtual user")
+		return 0, errors.Wrap(err, "failed to create virtual user")
 	}
 
-	return nil
+	return user.Id, nil
 }
 
 func (s *ServiceImpl) GetUserByEmail(ctx context.Context, email string) (bool, *User, error) {
@@ -118,3 +120,21 @@ func (s *ServiceImpl) UpdateUser(ctx context.Context, id uint, email, name, hash
 
 	return nil
 }
+
+// GetUserIdsShownOnGlobalLeaderboards returns the users that opted into being ranked across clubs.
+func (s *ServiceImpl) GetUserIdsShownOnGlobalLeaderboards(ctx context.Context) ([]uint, error) {
+	userIds, err := s.repo.GetUserIdsShownOnGlobalLeaderboards(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get users shown on global leaderboards")
+	}
+
+	return userIds, nil
+}
+
+func (s *ServiceImpl) SetShowOnGlobalLeaderboards(ctx context.Context, id uint, show bool) error {
+	if err := s.repo.SetShowOnGlobalLeaderboards(ctx, id, show); err != nil {
+		return errors.Wrapf(err, "failed to update global leaderboard setting of user %d", id)
+	}
+
+	return nil
+}
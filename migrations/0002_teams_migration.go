@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00002Teams adds teams and lets statistics and ratings belong to a team.
+var Migration00002Teams = &gormigrate.Migration{
+	ID: "teams_00002",
+	Migrate: func(tx *gorm.DB) error {
+		type Team struct {
+			Id uint `gorm:"primaryKey"`
+
+			ClubId  uint   `gorm:"not null;uniqueIndex:idx_teams_club_members"`
+			Members string `gorm:"not null;size:255;uniqueIndex:idx_teams_club_members"`
+			UserIds []uint `gorm:"serializer:json;not null"`
+
+			CreatedAt time.Time
+		}
+
+		type Statistic struct {
+			Id uint `gorm:"primaryKey"`
+
+			TeamId uint `gorm:"index"`
+		}
+
+		type Rating struct {
+			Id uint `gorm:"primaryKey"`
+
+			TeamId uint `gorm:"index"`
+		}
+
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			TeamAId uint `gorm:"index"`
+			TeamBId uint `gorm:"index"`
+		}
+
+		return tx.AutoMigrate(
+			&Team{},
+			&Statistic{},
+			&Rating{},
+			&Match{},
+		)
+	},
+}
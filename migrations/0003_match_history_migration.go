@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00003MatchHistory adds the columns needed to filter the match history.
+// Existing matches are left without a club and game, which Migration00018ClubScopeBackfill fills in.
+var Migration00003MatchHistory = &gormigrate.Migration{
+	ID: "match_history_00003",
+	Migrate: func(tx *gorm.DB) error {
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			ClubId uint `gorm:"index"`
+			GameId uint `gorm:"index"`
+			Rated  bool
+		}
+
+		return tx.AutoMigrate(
+			&Match{},
+		)
+	},
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00004MatchSubmitter records who submitted a match, so only they or club managers can change it.
+var Migration00004MatchSubmitter = &gormigrate.Migration{
+	ID: "match_submitter_00004",
+	Migrate: func(tx *gorm.DB) error {
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			SubmittedBy uint `gorm:"index"`
+		}
+
+		return tx.AutoMigrate(
+			&Match{},
+		)
+	},
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00005MatchConfirmation adds the confirmation workflow to matches.
+// Matches recorded before it are considered confirmed at the time they were created.
+var Migration00005MatchConfirmation = &gormigrate.Migration{
+	ID: "match_confirmation_00005",
+	Migrate: func(tx *gorm.DB) error {
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			Status      string `gorm:"not null;index;default:confirmed"`
+			ConfirmedBy uint
+			ConfirmedAt *time.Time `gorm:"index"`
+			DisputedBy  uint
+		}
+
+		if err := tx.AutoMigrate(&Match{}); err != nil {
+			return err
+		}
+
+		return tx.Exec("UPDATE matches SET confirmed_at = created_at WHERE confirmed_at IS NULL AND status = ?", "confirmed").Error
+	},
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00006ClubStandings scopes statistics and ratings to the club they were earned in.
+// Existing statistics and ratings are left without a club, which Migration00018ClubScopeBackfill fills in.
+var Migration00006ClubStandings = &gormigrate.Migration{
+	ID: "club_standings_00006",
+	Migrate: func(tx *gorm.DB) error {
+		type Statistic struct {
+			Id uint `gorm:"primaryKey"`
+
+			ClubId uint `gorm:"index"`
+		}
+
+		type Rating struct {
+			Id uint `gorm:"primaryKey"`
+
+			ClubId uint `gorm:"index"`
+		}
+
+		return tx.AutoMigrate(&Statistic{}, &Rating{})
+	},
+}
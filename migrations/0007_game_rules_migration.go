@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00007GameRules adds scoring rules to games.
+// Existing games get no rules besides allowing draws, so they keep accepting the results they accepted before.
+var Migration00007GameRules = &gormigrate.Migration{
+	ID: "game_rules_00007",
+	Migrate: func(tx *gorm.DB) error {
+		type Game struct {
+			Id uint `gorm:"primaryKey"`
+
+			PointsToWinSet int  `gorm:"not null;default:0"`
+			WinByTwo       bool `gorm:"not null;default:false"`
+			BestOf         int  `gorm:"not null;default:0"`
+			AllowDraws     bool `gorm:"not null;default:true"`
+			MaxScore       int  `gorm:"not null;default:0"`
+		}
+
+		return tx.AutoMigrate(&Game{})
+	},
+}
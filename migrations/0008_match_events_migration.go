@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00008MatchEvents adds the event log of matches.
+var Migration00008MatchEvents = &gormigrate.Migration{
+	ID: "match_events_00008",
+	Migrate: func(tx *gorm.DB) error {
+		type MatchEvent struct {
+			Id uint `gorm:"primaryKey"`
+
+			MatchId  uint `gorm:"not null;index:idx_match_events_match_sequence,unique"`
+			Sequence int  `gorm:"not null;index:idx_match_events_match_sequence,unique"`
+
+			Type     string `gorm:"not null"`
+			Set      int    `gorm:"not null"`
+			Side     string `gorm:"not null"`
+			UserId   uint
+			Position string
+
+			OccurredAt time.Time `gorm:"not null"`
+		}
+
+		return tx.AutoMigrate(&MatchEvent{})
+	},
+}
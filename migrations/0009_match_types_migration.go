@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00009MatchTypes adds free-for-all placements and scores to matches.
+// Every existing match was recorded as a team match.
+var Migration00009MatchTypes = &gormigrate.Migration{
+	ID: "match_types_00009",
+	Migrate: func(tx *gorm.DB) error {
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			Type       string `gorm:"not null;default:team"`
+			Placements []int  `gorm:"serializer:json"`
+			Scores     []int  `gorm:"serializer:json"`
+		}
+
+		return tx.AutoMigrate(&Match{})
+	},
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00010MatchIdempotencyKey adds the idempotency key of match submissions.
+// It is unique per submitter, existing matches have none.
+var Migration00010MatchIdempotencyKey = &gormigrate.Migration{
+	ID: "match_idempotency_key_00010",
+	Migrate: func(tx *gorm.DB) error {
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			SubmittedBy    uint    `gorm:"index;uniqueIndex:idx_matches_submitted_by_idempotency_key"`
+			IdempotencyKey *string `gorm:"size:255;uniqueIndex:idx_matches_submitted_by_idempotency_key"`
+		}
+
+		return tx.AutoMigrate(&Match{})
+	},
+}
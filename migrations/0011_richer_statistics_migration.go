@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00011RicherStatistics adds goals, sets, longest streaks and when a player last played to statistics.
+// Existing statistics start out empty, run the recalculate command to replay them from the match history.
+var Migration00011RicherStatistics = &gormigrate.Migration{
+	ID: "richer_statistics_00011",
+	Migrate: func(tx *gorm.DB) error {
+		type Statistic struct {
+			Id uint `gorm:"primaryKey"`
+
+			GoalsFor          int
+			GoalsAgainst      int
+			SetsWon           int
+			SetsLost          int
+			LongestWinStreak  int
+			LongestLossStreak int
+			LastPlayedAt      *time.Time
+		}
+
+		return tx.AutoMigrate(&Statistic{})
+	},
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00012Achievements adds the badges awarded to users.
+var Migration00012Achievements = &gormigrate.Migration{
+	ID: "achievements_00012",
+	Migrate: func(tx *gorm.DB) error {
+		type Achievement struct {
+			Id uint `gorm:"primaryKey"`
+
+			UserId  uint   `gorm:"not null;uniqueIndex:idx_achievements_user_badge"`
+			Badge   string `gorm:"not null;size:64;uniqueIndex:idx_achievements_user_badge"`
+			ClubId  uint   `gorm:"index"`
+			GameId  uint
+			MatchId uint
+
+			AwardedAt time.Time `gorm:"not null"`
+		}
+
+		return tx.AutoMigrate(&Achievement{})
+	},
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00013LeaderboardSnapshots adds the periodic snapshots of leaderboards that rank changes and trends are measured against.
+var Migration00013LeaderboardSnapshots = &gormigrate.Migration{
+	ID: "leaderboard_snapshots_00013",
+	Migrate: func(tx *gorm.DB) error {
+		type Snapshot struct {
+			Id uint `gorm:"primaryKey"`
+
+			ClubId  uint      `gorm:"not null;index:idx_snapshots_leaderboard"`
+			GameId  uint      `gorm:"not null;index:idx_snapshots_leaderboard"`
+			Type    string    `gorm:"not null;size:32;index:idx_snapshots_leaderboard"`
+			TakenAt time.Time `gorm:"not null;index:idx_snapshots_leaderboard"`
+
+			UserId uint
+			TeamId uint
+			Rank   int
+			Value  float64
+		}
+
+		return tx.AutoMigrate(&Snapshot{})
+	},
+}
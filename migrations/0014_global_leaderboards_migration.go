@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00014GlobalLeaderboards lets users opt into leaderboards across clubs, which nobody is on until they do.
+var Migration00014GlobalLeaderboards = &gormigrate.Migration{
+	ID: "global_leaderboards_00014",
+	Migrate: func(tx *gorm.DB) error {
+		type User struct {
+			Id uint `gorm:"primaryKey"`
+
+			ShowOnGlobalLeaderboards bool `gorm:"not null;default:false"`
+		}
+
+		return tx.AutoMigrate(&User{})
+	},
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00015ClubInvites replaces whether a membership is accepted with the status of the invite,
+// and adds who sent the invite and when it expires. Invites sent before it never expire.
+var Migration00015ClubInvites = &gormigrate.Migration{
+	ID: "club_invites_00015",
+	Migrate: func(tx *gorm.DB) error {
+		type ClubsUsers struct {
+			Id uint `gorm:"primaryKey"`
+
+			Status    string `gorm:"not null;index;default:pending"`
+			InvitedBy uint
+			ExpiresAt *time.Time
+		}
+
+		if err := tx.AutoMigrate(&ClubsUsers{}); err != nil {
+			return err
+		}
+
+		if err := tx.Exec("UPDATE clubs_users SET status = ? WHERE accepted = ?", "accepted", true).Error; err != nil {
+			return err
+		}
+
+		return tx.Migrator().DropColumn(&ClubsUsers{}, "accepted")
+	},
+}
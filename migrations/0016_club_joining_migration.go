@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00016ClubJoining adds invite links and requests to join clubs, which clubs can be found by once discoverable.
+var Migration00016ClubJoining = &gormigrate.Migration{
+	ID: "club_joining_00016",
+	Migrate: func(tx *gorm.DB) error {
+		type Club struct {
+			Id uint `gorm:"primaryKey"`
+
+			Discoverable bool `gorm:"not null;default:false"`
+		}
+
+		type InviteLink struct {
+			Id uint `gorm:"primaryKey"`
+
+			ClubId    uint   `gorm:"not null;index"`
+			Code      string `gorm:"not null;size:32;uniqueIndex"`
+			CreatedBy uint
+			MaxUses   int
+			Uses      int
+			ExpiresAt time.Time `gorm:"not null"`
+			RevokedAt *time.Time
+
+			CreatedAt time.Time
+		}
+
+		type JoinRequest struct {
+			Id uint `gorm:"primaryKey"`
+
+			ClubId     uint   `gorm:"not null;index"`
+			UserId     uint   `gorm:"not null;index"`
+			Message    string `gorm:"size:500"`
+			Status     string `gorm:"not null;index;default:pending"`
+			ReviewedBy uint
+
+			CreatedAt time.Time
+		}
+
+		return tx.AutoMigrate(&Club{}, &InviteLink{}, &JoinRequest{})
+	},
+}
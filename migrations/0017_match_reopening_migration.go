@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00017MatchReopening adds when a corrected match was sent back to pending,
+// which the confirmation timeout counts from instead of when the match was created.
+var Migration00017MatchReopening = &gormigrate.Migration{
+	ID: "match_reopening_00017",
+	Migrate: func(tx *gorm.DB) error {
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			ReopenedAt *time.Time
+		}
+
+		return tx.AutoMigrate(&Match{})
+	},
+}
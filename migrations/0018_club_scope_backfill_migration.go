@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// clubScopeBackfills fill in the club and game of rows recorded before matches, statistics and ratings
+// were scoped to them, wherever only one club or game fits. Rows belonging to a team take the club of the team.
+// Otherwise a match takes the only club the first player of team A is a member of, and statistics and ratings
+// the only club their player is a member of. Statistics and ratings were never given a game either,
+// so all of them take the only game played in their club, as matches do.
+var clubScopeBackfills = []string{
+	`UPDATE matches m JOIN teams t ON t.id = m.team_a_id
+		SET m.club_id = t.club_id
+		WHERE m.club_id = 0`,
+	`UPDATE matches m JOIN (
+			SELECT user_id, MIN(club_id) AS club_id FROM clubs_users
+			WHERE status = 'accepted'
+			GROUP BY user_id HAVING COUNT(DISTINCT club_id) = 1
+		) cu ON cu.user_id = JSON_EXTRACT(m.team_a, '$[0]')
+		SET m.club_id = cu.club_id
+		WHERE m.club_id = 0`,
+	`UPDATE matches m JOIN (
+			SELECT club_id, MIN(game_id) AS game_id FROM clubs_games
+			GROUP BY club_id HAVING COUNT(DISTINCT game_id) = 1
+		) cg ON cg.club_id = m.club_id
+		SET m.game_id = cg.game_id
+		WHERE m.game_id = 0`,
+	`UPDATE statistics s JOIN teams t ON t.id = s.team_id
+		SET s.club_id = t.club_id
+		WHERE s.club_id = 0 AND s.team_id <> 0`,
+	`UPDATE ratings r JOIN teams t ON t.id = r.team_id
+		SET r.club_id = t.club_id
+		WHERE r.club_id = 0 AND r.team_id <> 0`,
+	`UPDATE statistics s JOIN (
+			SELECT user_id, MIN(club_id) AS club_id FROM clubs_users
+			WHERE status = 'accepted'
+			GROUP BY user_id HAVING COUNT(DISTINCT club_id) = 1
+		) cu ON cu.user_id = s.user_id
+		SET s.club_id = cu.club_id
+		WHERE s.club_id = 0 AND s.user_id <> 0 AND s.team_id = 0`,
+	`UPDATE ratings r JOIN (
+			SELECT user_id, MIN(club_id) AS club_id FROM clubs_users
+			WHERE status = 'accepted'
+			GROUP BY user_id HAVING COUNT(DISTINCT club_id) = 1
+		) cu ON cu.user_id = r.user_id
+		SET r.club_id = cu.club_id
+		WHERE r.club_id = 0 AND r.user_id <> 0 AND r.team_id = 0`,
+	`UPDATE statistics s JOIN (
+			SELECT club_id, MIN(game_id) AS game_id FROM clubs_games
+			GROUP BY club_id HAVING COUNT(DISTINCT game_id) = 1
+		) cg ON cg.club_id = s.club_id
+		SET s.game_id = cg.game_id
+		WHERE s.game_id = 0`,
+	`UPDATE ratings r JOIN (
+			SELECT club_id, MIN(game_id) AS game_id FROM clubs_games
+			GROUP BY club_id HAVING COUNT(DISTINCT game_id) = 1
+		) cg ON cg.club_id = r.club_id
+		SET r.game_id = cg.game_id
+		WHERE r.game_id = 0`,
+}
+
+// Migration00018ClubScopeBackfill backfills the club and game that migrations 00003 and 00006 added to matches,
+// statistics and ratings, which were left at 0 and so missing from every club.
+// Rows that fit more than one club or game are left at 0, assign their matches by hand if needed,
+// and run the recalculate command for every club afterwards to rebuild statistics and ratings from its matches.
+// Recalculating creates rows of its own, so statistics and ratings still at club or game 0 after it are orphaned and should be deleted.
+var Migration00018ClubScopeBackfill = &gormigrate.Migration{
+	ID: "club_scope_backfill_00018",
+	Migrate: func(tx *gorm.DB) error {
+		for _, backfill := range clubScopeBackfills {
+			if err := tx.Exec(backfill).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00019MatchSubmissionHash adds the fingerprint of the submission a match was recorded from with an idempotency key.
+// Existing matches have none, so a retry of them is not checked against the first submission.
+var Migration00019MatchSubmissionHash = &gormigrate.Migration{
+	ID: "match_submission_hash_00019",
+	Migrate: func(tx *gorm.DB) error {
+		type Match struct {
+			Id uint `gorm:"primaryKey"`
+
+			SubmissionHash string `gorm:"size:64"`
+		}
+
+		return tx.AutoMigrate(&Match{})
+	},
+}
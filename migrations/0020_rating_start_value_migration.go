@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// Migration00020RatingStartValue makes the starting rating the column default. The default of 1000 replaced the
+// starting rating of 0 on insert, which Glicko then clamped to the highest rating there is.
+// Ratings still at 1000 never played and are reset, ratings that played since are only put right by running recalculate.
+var Migration00020RatingStartValue = &gormigrate.Migration{
+	ID: "rating_start_value_00020",
+	Migrate: func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE ratings ALTER COLUMN value SET DEFAULT 0").Error; err != nil {
+			return err
+		}
+
+		return tx.Exec("UPDATE ratings SET value = 0 WHERE value = 1000").Error
+	},
+}
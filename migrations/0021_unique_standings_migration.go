@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// standingsDeduplications keep the first of the statistics and ratings a user or team has more than once for a game in a club,
+// which matches confirmed at the same time could create.
+var standingsDeduplications = []string{
+	`DELETE s FROM statistics s JOIN statistics d
+		ON d.club_id = s.club_id AND d.game_id = s.game_id AND d.user_id = s.user_id AND d.team_id = s.team_id AND d.id < s.id`,
+	`DELETE r FROM ratings r JOIN ratings d
+		ON d.club_id = r.club_id AND d.game_id = r.game_id AND d.user_id = r.user_id AND d.team_id = r.team_id AND d.id < r.id`,
+}
+
+// Migration00021UniqueStandings makes statistics and ratings unique per user or team, game and club.
+// Duplicates are removed first, run the recalculate command for their clubs afterwards to rebuild the rows that were kept.
+var Migration00021UniqueStandings = &gormigrate.Migration{
+	ID: "unique_standings_00021",
+	Migrate: func(tx *gorm.DB) error {
+		for _, deduplication := range standingsDeduplications {
+			if err := tx.Exec(deduplication).Error; err != nil {
+				return err
+			}
+		}
+
+		type Statistic struct {
+			Id uint `gorm:"primaryKey"`
+
+			UserId uint `gorm:"not null;uniqueIndex:idx_statistics_standing"`
+			TeamId uint `gorm:"index;uniqueIndex:idx_statistics_standing"`
+			ClubId uint `gorm:"index;uniqueIndex:idx_statistics_standing"`
+			GameId uint `gorm:"not null;uniqueIndex:idx_statistics_standing"`
+		}
+
+		type Rating struct {
+			Id uint `gorm:"primaryKey"`
+
+			UserId uint `gorm:"not null;uniqueIndex:idx_ratings_standing"`
+			TeamId uint `gorm:"index;uniqueIndex:idx_ratings_standing"`
+			ClubId uint `gorm:"index;uniqueIndex:idx_ratings_standing"`
+			GameId uint `gorm:"not null;uniqueIndex:idx_ratings_standing"`
+		}
+
+		return tx.AutoMigrate(&Statistic{}, &Rating{})
+	},
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type (
+	txKey    struct{}
+	hooksKey struct{}
+)
+
+// Transactor runs a unit of work inside a single database transaction.
+// The transaction is carried by the context handed to the unit of work, so any
+// repository resolving its connection through Conn takes part in it.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) Transactor {
+	return &transactor{
+		db: db,
+	}
+}
+
+// Transaction commits if fn returns nil and rolls back otherwise.
+// If ctx already carries a transaction fn joins it, so units of work can be nested freely.
+// Hooks registered with AfterCommit run once the outermost transaction has committed.
+func (t *transactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	hooks := &[]func(){}
+	err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(context.WithValue(ctx, txKey{}, tx), hooksKey{}, hooks))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range *hooks {
+		hook()
+	}
+
+	return nil
+}
+
+// AfterCommit runs hook once the transaction carried by ctx commits, and never if it rolls back.
+// Without a transaction hook runs right away. This keeps others from hearing about changes that might still be undone.
+func AfterCommit(ctx context.Context, hook func()) {
+	if hooks, ok := ctx.Value(hooksKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, hook)
+		return
+	}
+
+	hook()
+}
+
+// Conn returns the transaction carried by ctx, or db if there is none.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	return db.WithContext(ctx)
+}
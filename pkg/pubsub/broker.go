@@ -0,0 +1,78 @@
+package pubsub
+
+import "sync"
+
+// Broker hands every message published to it to the subscribers interested in it.
+// Publishing never blocks, subscribers that fall behind miss the oldest of the messages they have not picked up.
+type Broker[T any] struct {
+	mu          sync.Mutex
+	subscribers map[chan T]func(T) bool
+	hooks       []func(T)
+}
+
+func NewBroker[T any]() *Broker[T] {
+	return &Broker[T]{
+		subscribers: map[chan T]func(T) bool{},
+	}
+}
+
+// Subscribe returns a channel receiving the messages the filter accepts, until unsubscribe is called.
+// Up to buffer messages are held for the subscriber, so a buffer of 1 only ever holds the latest message.
+// A buffer below 1 is taken as 1, as publishing could not make room in an unbuffered channel and would block.
+func (b *Broker[T]) Subscribe(filter func(T) bool, buffer int) (messages <-chan T, unsubscribe func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+
+	subscription := make(chan T, buffer)
+
+	b.mu.Lock()
+	b.subscribers[subscription] = filter
+	b.mu.Unlock()
+
+	unsubscribe = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[subscription]; ok {
+			delete(b.subscribers, subscription)
+			close(subscription)
+		}
+	}
+
+	return subscription, unsubscribe
+}
+
+// OnPublish runs the hook on every message as it is published, before any subscriber can receive it.
+// Hooks are for what has to happen before anyone acts on the message, so they should be quick and must not publish.
+func (b *Broker[T]) OnPublish(hook func(T)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.hooks = append(b.hooks, hook)
+}
+
+func (b *Broker[T]) Publish(message T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, hook := range b.hooks {
+		hook(message)
+	}
+
+	for subscription, filter := range b.subscribers {
+		if !filter(message) {
+			continue
+		}
+
+		// Make room by dropping the oldest message the subscriber has not picked up yet.
+		if len(subscription) == cap(subscription) {
+			select {
+			case <-subscription:
+			default:
+			}
+		}
+
+		subscription <- message
+	}
+}